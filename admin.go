@@ -0,0 +1,339 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Shown instead of the value of env_secret fields.
+const redacted = "[REDACTED]"
+
+// An AdminHandler serves a Loader's configuration and lets fields tagged
+// env_mutable be overridden at runtime:
+//
+//	GET /        the fields, their values (env_secret values redacted) and the
+//	             source which set them
+//	GET /audit   the changes made through the handler
+//	PUT /KEY     override the field named KEY with the request body, for the
+//	             duration given by ?ttl= (e.g. ttl=15m) if any
+//	DELETE /KEY  remove the override
+//
+// Overrides are a source at the front of the chain so they survive reloads.
+// The new value is parsed and the structs validated before the override is
+// kept; the fields are set directly so concurrent readers should use a Store.
+type AdminHandler struct {
+	// The name of the user making a request recorded in the audit trail, the
+	// basic auth user or the remote address if nil.
+	User func(r *http.Request) string
+
+	loader    *Loader
+	overrides *overrideSource
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	audit  []AuditEntry
+}
+
+// A change made through an AdminHandler.
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"` // empty when a TTL expires
+	Action  string    `json:"action"`         // set, delete or expire
+	Key     string    `json:"key"`
+	Old     string    `json:"old"`
+	New     string    `json:"new"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// A field as served by an AdminHandler.
+type adminField struct {
+	Name    string     `json:"name"`
+	Key     string     `json:"key"`
+	Value   string     `json:"value"`
+	Source  string     `json:"source,omitempty"`
+	Mutable bool       `json:"mutable,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Create an AdminHandler for l, adding its overrides to the front of l's
+// source chain.
+func NewAdminHandler(l *Loader) *AdminHandler {
+	h := &AdminHandler{
+		loader:    l,
+		overrides: &overrideSource{values: make(map[string]override)},
+		timers:    make(map[string]*time.Timer),
+	}
+
+	l.mu.Lock()
+	l.sources = append([]Source{h.overrides}, l.sources...)
+	l.mu.Unlock()
+
+	return h
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(r.URL.Path, "/")
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		writeJSON(w, map[string]interface{}{"fields": h.fields()})
+	case r.Method == http.MethodGet && key == "audit":
+		writeJSON(w, map[string]interface{}{"audit": h.Audit()})
+	case r.Method == http.MethodPut && key != "":
+		h.put(w, r, key)
+	case r.Method == http.MethodDelete && key != "":
+		h.delete(w, r, key)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// The changes made through the handler, oldest first.
+func (h *AdminHandler) Audit() []AuditEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]AuditEntry(nil), h.audit...)
+}
+
+func (h *AdminHandler) fields() []adminField {
+	l := h.loader
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fields := make([]adminField, 0, len(l.fields))
+	for _, f := range l.fields {
+		af := adminField{
+			Name:    f.strct + "." + f.name,
+			Key:     f.key,
+			Value:   f.display(),
+			Source:  f.source,
+			Mutable: f.mutable,
+		}
+		if o, ok := h.overrides.get(f.key); ok && !o.expires.IsZero() {
+			expires := o.expires
+			af.Expires = &expires
+		}
+		fields = append(fields, af)
+	}
+	return fields
+}
+
+func (h *AdminHandler) put(w http.ResponseWriter, r *http.Request,
+	key string) {
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	value := strings.TrimSpace(string(body))
+
+	var ttl time.Duration
+	if s := r.URL.Query().Get("ttl"); s != "" {
+		if ttl, err = ParseDuration(s); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid ttl %q", s),
+				http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	o := override{value: value, expires: expires}
+
+	status, err := h.apply(key, &o, "set", h.user(r))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	h.stopTimer(key)
+	if ttl > 0 {
+		h.timers[key] = time.AfterFunc(ttl, func() { h.expire(key, o) })
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) delete(w http.ResponseWriter, r *http.Request,
+	key string) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.overrides.get(key); !ok {
+		http.Error(w, fmt.Sprintf("%s is not overridden", key),
+			http.StatusNotFound)
+		return
+	}
+
+	if status, err := h.apply(key, nil, "delete", h.user(r)); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	h.stopTimer(key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Remove the override o of key when its TTL expires, unless it has been
+// replaced.
+func (h *AdminHandler) expire(key string, o override) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cur, ok := h.overrides.get(key); !ok || cur != o {
+		return
+	}
+	delete(h.timers, key)
+	if _, err := h.apply(key, nil, "expire", ""); err != nil {
+		h.loader.logger.Printf("config: reverting %s: %v", key, err)
+	}
+}
+
+// Override key with o (remove the override if nil) and set the field, keeping
+// the previous override if the value does not parse or validate. Called with
+// h.mu held.
+func (h *AdminHandler) apply(key string, o *override, action,
+	user string) (int, error) {
+
+	l := h.loader
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var f *field
+	for _, x := range l.fields {
+		if x.key == key {
+			f = x
+		}
+	}
+	if f == nil {
+		return http.StatusNotFound, fmt.Errorf("Unknown field %s", key)
+	}
+	if !f.mutable {
+		return http.StatusForbidden, fmt.Errorf("%s is not mutable", key)
+	}
+
+	old := f.display()
+	prev, hadPrev := h.overrides.get(key)
+	if o != nil {
+		h.overrides.set(key, *o)
+	} else {
+		h.overrides.remove(key)
+	}
+
+	if err := l.set([]string{key}); err != nil {
+		if hadPrev {
+			h.overrides.set(key, prev)
+		} else {
+			h.overrides.remove(key)
+		}
+		if err := l.set([]string{key}); err != nil {
+			l.logger.Printf("config: restoring %s: %v", key, err)
+		}
+		return http.StatusBadRequest, err
+	}
+
+	e := AuditEntry{
+		Time:   time.Now(),
+		User:   user,
+		Action: action,
+		Key:    key,
+		Old:    old,
+		New:    f.display(),
+	}
+	if o != nil {
+		e.Expires = o.expires
+	}
+	h.audit = append(h.audit, e)
+	l.logger.Printf("config: %s %s=%s (was %s) by %q", e.Action, e.Key, e.New,
+		e.Old, e.User)
+
+	return http.StatusOK, nil
+}
+
+func (h *AdminHandler) stopTimer(key string) {
+	if t, ok := h.timers[key]; ok {
+		t.Stop()
+		delete(h.timers, key)
+	}
+}
+
+func (h *AdminHandler) user(r *http.Request) string {
+	if h.User != nil {
+		return h.User(r)
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	return r.RemoteAddr
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// An override of a field made through an AdminHandler.
+type override struct {
+	value   string
+	expires time.Time // zero if it does not expire
+}
+
+// The overrides made through an AdminHandler.
+type overrideSource struct {
+	mu     sync.RWMutex
+	values map[string]override
+}
+
+func (s *overrideSource) Name() string {
+	return "admin"
+}
+
+func (s *overrideSource) Lookup(key string) (string, bool, error) {
+	o, ok := s.get(key)
+	return o.value, ok, nil
+}
+
+func (s *overrideSource) get(key string) (override, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.values[key]
+	return o, ok
+}
+
+func (s *overrideSource) set(key string, o override) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = o
+}
+
+func (s *overrideSource) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+// The field's current value as it would be given to a source, redacted if it
+// is a secret.
+func (f *field) display() string {
+	if f.secret {
+		return redacted
+	}
+	return f.current()
+}
+
+// The field's current value in the form its Value parses.
+func (f *field) current() string {
+	return formatValue(f.value.get(), f.vt)
+}
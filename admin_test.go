@@ -0,0 +1,234 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type adminTest struct {
+	Rate     int           `env_name:"rate" env_def:"100" env_mutable:""`
+	Level    string        `env_name:"level" env_def:"info" env_mutable:""`
+	Timeout  time.Duration `env_name:"timeout" env_def:"36h" env_mutable:""`
+	Password string        `env_name:"password" env_def:"hunter2" env_secret:""`
+	Port     int           `env_name:"port" env_def:"80"`
+}
+
+func (a *adminTest) Validate() error {
+	if a.Rate > 1000 {
+		return errors.New("rate above 1000")
+	}
+	return nil
+}
+
+func newAdmin(t *testing.T) (*adminTest, *AdminHandler, *testLogger) {
+	var cfg adminTest
+	logger := &testLogger{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithEnvMap(nil), WithLogger(logger))
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	return &cfg, NewAdminHandler(l), logger
+}
+
+func adminRequest(h http.Handler, method, path,
+	body string) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.SetBasicAuth("alice", "")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAdminGet(t *testing.T) {
+	_, h, _ := newAdmin(t)
+
+	w := adminRequest(h, "GET", "/", "")
+	if w.Code != http.StatusOK {
+		t.Fatal("GET failed", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "hunter2") {
+		t.Error("Secret should be redacted", w.Body)
+	}
+
+	var doc struct {
+		Fields []adminField
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]adminField)
+	for _, f := range doc.Fields {
+		fields[f.Key] = f
+	}
+	if f := fields["timeout"]; f.Value != "1d12h" || f.Source != "default" ||
+		!f.Mutable || f.Name != "adminTest.Timeout" {
+		t.Error("Unexpected timeout", f)
+	}
+	if f := fields["password"]; f.Value != redacted {
+		t.Error("Unexpected password", f)
+	}
+	if f := fields["port"]; f.Value != "80" || f.Mutable {
+		t.Error("Unexpected port", f)
+	}
+}
+
+func TestAdminPut(t *testing.T) {
+	cfg, h, logger := newAdmin(t)
+
+	if w := adminRequest(h, "PUT", "/rate", "500"); w.Code != 204 {
+		t.Fatal("PUT failed", w.Code, w.Body)
+	}
+	if cfg.Rate != 500 {
+		t.Error("rate should be overridden", cfg.Rate)
+	}
+
+	// Overrides survive a reload
+	if err := h.loader.Reload(); err != nil || cfg.Rate != 500 {
+		t.Error("Override lost on reload", cfg.Rate, err)
+	}
+
+	if w := adminRequest(h, "PUT", "/rate", "5000"); w.Code != 400 ||
+		cfg.Rate != 500 {
+		t.Error("Invalid struct should be rejected", w.Code, cfg.Rate)
+	}
+	if w := adminRequest(h, "PUT", "/rate", "lots"); w.Code != 400 ||
+		cfg.Rate != 500 {
+		t.Error("Unparsable value should be rejected", w.Code, cfg.Rate)
+	}
+	if w := adminRequest(h, "PUT", "/port", "81"); w.Code != 403 {
+		t.Error("Immutable field should be forbidden", w.Code)
+	}
+	if w := adminRequest(h, "PUT", "/nope", "1"); w.Code != 404 {
+		t.Error("Unknown field should not be found", w.Code)
+	}
+
+	if w := adminRequest(h, "DELETE", "/rate", ""); w.Code != 204 ||
+		cfg.Rate != 100 {
+		t.Error("DELETE should revert", w.Code, cfg.Rate)
+	}
+	if w := adminRequest(h, "DELETE", "/rate", ""); w.Code != 404 {
+		t.Error("DELETE of no override should not be found", w.Code)
+	}
+
+	audit := h.Audit()
+	if len(audit) != 2 {
+		t.Fatal("Expected set and delete in the audit trail", audit)
+	}
+	if e := audit[0]; e.Action != "set" || e.User != "alice" ||
+		e.Old != "100" || e.New != "500" {
+		t.Error("Unexpected audit entry", e)
+	}
+	if e := audit[1]; e.Action != "delete" || e.Old != "500" ||
+		e.New != "100" {
+		t.Error("Unexpected audit entry", e)
+	}
+	if len(logger.messages) != 2 {
+		t.Error("Expected changes to be logged", logger.messages)
+	}
+}
+
+func TestAdminTTL(t *testing.T) {
+	cfg, h, _ := newAdmin(t)
+
+	if w := adminRequest(h, "PUT", "/level?ttl=10ms", "debug"); w.Code != 204 {
+		t.Fatal("PUT failed", w.Code, w.Body)
+	}
+	if cfg.Level != "debug" {
+		t.Error("level should be overridden", cfg.Level)
+	}
+	if !strings.Contains(adminRequest(h, "GET", "/", "").Body.String(),
+		`"expires"`) {
+		t.Error("Expiry should be shown")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(h.Audit()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	audit := h.Audit()
+	if len(audit) != 2 || audit[1].Action != "expire" {
+		t.Fatal("Expected the override to expire", audit)
+	}
+	h.loader.mu.Lock()
+	level := cfg.Level
+	h.loader.mu.Unlock()
+	if level != "info" {
+		t.Error("level should revert", level)
+	}
+
+	if w := adminRequest(h, "PUT", "/level?ttl=soon", "debug"); w.Code != 400 {
+		t.Error("Invalid ttl should be rejected", w.Code)
+	}
+}
+
+type adminNoDefault struct {
+	Limit int `env_name:"limit" env_mutable:""`
+}
+
+func TestAdminNoDefault(t *testing.T) {
+	var cfg adminNoDefault
+	l, _, err := testLoad(nil, nil, []Option{WithLogger(&testLogger{})}, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewAdminHandler(l)
+
+	if w := adminRequest(h, "PUT", "/limit", "50"); w.Code != 204 {
+		t.Fatal("PUT failed", w.Code, w.Body)
+	}
+	if w := adminRequest(h, "DELETE", "/limit", ""); w.Code != 204 {
+		t.Fatal("DELETE failed", w.Code, w.Body)
+	}
+	audit := h.Audit()
+	if cfg.Limit != 0 || len(audit) != 2 || audit[1].Old != "50" ||
+		audit[1].New != "0" {
+		t.Error("Removing the override should restore the zero value",
+			cfg.Limit, audit)
+	}
+
+	if w := adminRequest(h, "PUT", "/limit?ttl=10ms", "50"); w.Code != 204 {
+		t.Fatal("PUT failed", w.Code, w.Body)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(h.Audit()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	l.mu.Lock()
+	limit := cfg.Limit
+	l.mu.Unlock()
+	if limit != 0 {
+		t.Error("The expired override should restore the zero value", limit)
+	}
+}
+
+type adminSecretDefault struct {
+	Password string `env_name:"password" env_short:"p" env_secret:""`
+}
+
+func (a *adminSecretDefault) Defaults() {
+	a.Password = "hunter2"
+}
+
+func TestAdminSecretDefault(t *testing.T) {
+	for _, s := range []interface{}{&adminTest{}, &adminSecretDefault{}} {
+		l, out, err := testLoad(nil, nil, nil, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.flags.PrintDefaults()
+		l.PrintDefaults()
+		if h := out.String(); strings.Contains(h, "hunter2") ||
+			!strings.Contains(h, "(default [REDACTED])") {
+			t.Error("Help should not show a secret default", h)
+		}
+	}
+}
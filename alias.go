@@ -0,0 +1,118 @@
+/*
+Aliases give a field additional flag/env names, generally its names before it
+was renamed. With env_deprecated using an alias (or the field's own name if it
+has no aliases) still works but logs a warning.
+*/
+package config
+
+import (
+	"log"
+	"strings"
+)
+
+// Used to warn about deprecated names, *log.Logger implements this.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Logs to the standard logger.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+var defaultLogger Logger = stdLogger{}
+
+// Log warnings with logger instead of the standard logger.
+func WithLogger(logger Logger) Option {
+	return func(l *Loader) {
+		l.logger = logger
+	}
+}
+
+// An additional name of a field and the flag registered for it.
+type alias struct {
+	key  string
+	flag ConfigFlag
+}
+
+// The field's name followed by its aliases.
+func (f *field) keys() []string {
+	keys := []string{f.key}
+	for _, a := range f.aliases {
+		keys = append(keys, a.key)
+	}
+	return keys
+}
+
+// Whether the field's name or one of its aliases is in keys.
+func (f *field) hasKey(keys []string) bool {
+	for _, k := range keys {
+		if f.hasName(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether key is the field's name or one of its aliases.
+func (f *field) hasName(key string) bool {
+	if key == f.key {
+		return true
+	}
+	for _, a := range f.aliases {
+		if a.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *field) aliasFlag(key string) ConfigFlag {
+	for _, a := range f.aliases {
+		if a.key == key {
+			return a.flag
+		}
+	}
+	return nil
+}
+
+// Whether using key should be warned about.
+func (f *field) isDeprecatedKey(key string) bool {
+	if !f.isDeprecated {
+		return false
+	}
+	return len(f.aliases) == 0 || key != f.key
+}
+
+// Register a flag for each of the names in the env_alias tag of the field p
+// points to, which are prefixed like the field's name when nested.
+func (l *Loader) registerAliases(m map[string]ConfigFlag, p interface{},
+	fld *field, tag fieldTags, path []string) error {
+
+	names, ok := tag.Lookup("env_alias")
+	if !ok {
+		return nil
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		key := strings.Join(append(append([]string(nil), path...), name), "_")
+
+		desc := "Alias for -" + fld.key
+		if fld.isDeprecated {
+			desc = "Deprecated alias for -" + fld.key + ": " + fld.deprecated
+		}
+
+		v, err := newValue(l.flags, m, p, fld.vt, key, nil, desc)
+		if err != nil {
+			return err
+		}
+		fld.aliases = append(fld.aliases, alias{key, v.(chainValue).configFlag()})
+	}
+	return nil
+}
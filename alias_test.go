@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Records the messages logged.
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+type aliasTest struct {
+	URL     string `env_name:"DB_URL" env_alias:"DATABASE,DB" env_deprecated:"use DB_URL instead"`
+	Timeout int    `env_alias:"WAIT"`
+	Legacy  bool   `env_deprecated:"no longer used"`
+}
+
+func loadAliases(args []string, env map[string]string) (*aliasTest,
+	*testLogger, *Loader, error) {
+
+	var ss aliasTest
+	logger := &testLogger{}
+	l, _, err := testLoad(args, env, []Option{WithLogger(logger)}, &ss)
+	return &ss, logger, l, err
+}
+
+func TestAlias(t *testing.T) {
+	ss, logger, _, err := loadAliases([]string{"-WAIT", "5"},
+		map[string]string{"DB_URL": "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.URL != "new" || ss.Timeout != 5 {
+		t.Error("Fields should be set by name or alias", ss)
+	}
+	if len(logger.messages) != 0 {
+		t.Error("Nothing deprecated was used", logger.messages)
+	}
+}
+
+func TestDeprecatedAlias(t *testing.T) {
+	ss, logger, l, err := loadAliases([]string{"-Legacy=true"},
+		map[string]string{"DATABASE": "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.URL != "old" || !ss.Legacy {
+		t.Error("Deprecated names should still be used", ss)
+	}
+	if len(logger.messages) != 2 ||
+		logger.messages[0] != "config: DATABASE is deprecated: use DB_URL instead" ||
+		logger.messages[1] != "config: Legacy is deprecated: no longer used" {
+		t.Error("Deprecated names should be warned about", logger.messages)
+	}
+
+	var help bytes.Buffer
+	l.printDefaults(&help)
+	h := help.String()
+	if !strings.Contains(h, "Deprecated alias for -DB_URL: use DB_URL instead") ||
+		!strings.Contains(h, "Legacy (deprecated: no longer used)") ||
+		!strings.Contains(h, "Alias for -Timeout") {
+		t.Error("Help should show the aliases and deprecations", h)
+	}
+}
+
+func TestAliasConflict(t *testing.T) {
+	_, _, _, err := loadAliases(nil,
+		map[string]string{"DB_URL": "new", "DB": "old"})
+	if err == nil || !strings.Contains(err.Error(), "DB_URL and DB") {
+		t.Error("Different values for a name and alias should fail", err)
+	}
+
+	ss, _, _, err := loadAliases(nil,
+		map[string]string{"DB_URL": "same", "DB": "same"})
+	if err != nil || ss.URL != "same" {
+		t.Error("The same value for a name and alias is fine", err)
+	}
+
+	ss, _, _, err = loadAliases([]string{"-DB_URL", "flag"},
+		map[string]string{"DB": "env"})
+	if err != nil || ss.URL != "flag" {
+		t.Error("Names in different sources use the precedence", err)
+	}
+}
+
+func TestAliasReload(t *testing.T) {
+	env := map[string]string{"DATABASE": "old", "WAIT": "5"}
+	ss, logger, l, err := loadAliases(nil, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changed keys are matched by alias too
+	env["WAIT"] = "6"
+	l.changed([]string{"WAIT"})
+	if ss.Timeout != 6 {
+		t.Error("A change to an alias should reload the field", ss.Timeout)
+	}
+
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.messages) != 1 {
+		t.Error("The deprecated name should only be warned about once",
+			logger.messages)
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// A number of bytes. Parsed from strings such as 100MiB, 1.5GB or 512k, KiB,
+// MiB... and the bare K, M... are powers of 1024, kB, MB... powers of 1000.
+// Int and uint64 fields tagged env_unit:"bytes" are parsed the same way.
+type ByteSize uint64
+
+const (
+	KiB ByteSize = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+	PiB
+	EiB
+)
+
+const (
+	KB ByteSize = 1000
+	MB          = KB * 1000
+	GB          = MB * 1000
+	TB          = GB * 1000
+	PB          = TB * 1000
+	EB          = PB * 1000
+)
+
+var byteUnits = map[string]ByteSize{
+	"":    1,
+	"b":   1,
+	"k":   KiB,
+	"m":   MiB,
+	"g":   GiB,
+	"t":   TiB,
+	"p":   PiB,
+	"e":   EiB,
+	"kib": KiB,
+	"mib": MiB,
+	"gib": GiB,
+	"tib": TiB,
+	"pib": PiB,
+	"eib": EiB,
+	"kb":  KB,
+	"mb":  MB,
+	"gb":  GB,
+	"tb":  TB,
+	"pb":  PB,
+	"eb":  EB,
+}
+
+type byteUnit struct {
+	size ByteSize
+	name string
+}
+
+// Units used by String, largest first.
+var stringUnits = []byteUnit{{EiB, "EiB"}, {EB, "EB"}, {PiB, "PiB"},
+	{PB, "PB"}, {TiB, "TiB"}, {TB, "TB"}, {GiB, "GiB"}, {GB, "GB"},
+	{MiB, "MiB"}, {MB, "MB"}, {KiB, "KiB"}, {KB, "kB"}}
+
+// Parse a size such as 100MiB, 1.5GB, 512k or 104857600. Fractions of a byte
+// are truncated, sizes which do not fit in a uint64 are an error.
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.TrimSpace(s[i:])
+
+	mult, ok := byteUnits[strings.ToLower(unit)]
+	if num == "" || !ok {
+		return 0, fmt.Errorf("Invalid byte size %q", s)
+	}
+
+	r, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, fmt.Errorf("Invalid byte size %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(mult))))
+
+	n := new(big.Int).Quo(r.Num(), r.Denom())
+	if !n.IsUint64() {
+		return 0, fmt.Errorf("Byte size %q overflows", s)
+	}
+	return ByteSize(n.Uint64()), nil
+}
+
+// The size in the largest unit which gives it exactly as a whole number or
+// with at most 3 decimal places under 1000, so it parses back to the same size.
+func (b ByteSize) String() string {
+	for _, u := range stringUnits {
+		if b < u.size {
+			continue
+		}
+		if s, ok := formatUnit(b, u.size); ok {
+			return s + u.name
+		}
+	}
+	return fmt.Sprintf("%dB", uint64(b))
+}
+
+// b in units of size if exact to 3 decimal places.
+func formatUnit(b, size ByteSize) (string, bool) {
+	whole, frac := b/size, b%size
+	if frac == 0 {
+		return fmt.Sprintf("%d", uint64(whole)), true
+	}
+	if whole >= 1000 {
+		return "", false
+	}
+
+	f := new(big.Int).SetUint64(uint64(frac))
+	f.Mul(f, big.NewInt(1000))
+	q, r := new(big.Int).QuoRem(f, new(big.Int).SetUint64(uint64(size)),
+		new(big.Int))
+	if r.Sign() != 0 {
+		return "", false
+	}
+	return strings.TrimRight(fmt.Sprintf("%d.%03d", uint64(whole), q.Uint64()),
+		"0"), true
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	v, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		s string
+		b ByteSize
+	}{
+		{"104857600", 100 * MiB},
+		{"100MiB", 100 * MiB},
+		{"1.5GB", 1500 * MB},
+		{"512k", 512 * KiB},
+		{"512 KB", 512 * KB},
+		{"2T", 2 * TiB},
+		{"10b", 10},
+		{"1.0001kB", 1000},
+		{"18446744073709551615", ^ByteSize(0)},
+	}
+
+	for _, test := range tests {
+		if b, err := ParseByteSize(test.s); err != nil || b != test.b {
+			t.Error(test.s, "should be", uint64(test.b), "not", uint64(b), err)
+		}
+	}
+
+	for _, s := range []string{"", "MiB", "1.2.3", "-1k", "5 bits", "16EiB",
+		"18446744073709551616"} {
+		if _, err := ParseByteSize(s); err == nil {
+			t.Error(s, "should not parse")
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := []struct {
+		b ByteSize
+		s string
+	}{
+		{0, "0B"},
+		{1000, "1kB"},
+		{1023, "1.023kB"},
+		{1025, "1.025kB"},
+		{1<<20 + 1, "1048577B"},
+		{1<<30 + 1, "1073741825B"},
+		{100 * MiB, "100MiB"},
+		{3 * GiB / 2, "1.5GiB"},
+		{1500 * MB, "1.5GB"},
+		{1234567, "1234567B"},
+	}
+
+	for _, test := range tests {
+		if s := test.b.String(); s != test.s {
+			t.Error(uint64(test.b), "should be", test.s, "not", s)
+		}
+		if b, err := ParseByteSize(test.s); err != nil || b != test.b {
+			t.Error(test.s, "should parse back to", uint64(test.b))
+		}
+	}
+}
+
+type byteSizeTest struct {
+	Buffer ByteSize `env_def:"64KiB"`
+	Cache  int      `env_unit:"bytes" env_def:"1GB"`
+	Upload uint64   `env_unit:"bytes"`
+}
+
+func TestByteSizeLoad(t *testing.T) {
+	var ss byteSizeTest
+
+	var help bytes.Buffer
+	env := map[string]string{"Upload": "10M"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&help)
+	l := NewLoader(WithFlagSet(fs, []string{"-Cache", "512k"}),
+		WithEnv(mapLookup(env)))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.Buffer != 64*KiB || ss.Cache != 512*1024 || ss.Upload != 10<<20 {
+		t.Error("Sizes should be parsed", ss)
+	}
+
+	fs.PrintDefaults()
+	if !strings.Contains(help.String(), "(default 64KiB)") ||
+		!strings.Contains(help.String(), "(default 1GB)") {
+		t.Error("Help should show human friendly defaults", help.String())
+	}
+}
+
+func TestByteSizeOverflow(t *testing.T) {
+	ss := struct {
+		Field int64
+	}{}
+
+	v := ByteSizeValue{"name", false, 0, &ByteSizeFlag{}, &ss.Field}
+
+	if err := v.parse("9EiB"); err == nil {
+		t.Error("9EiB should overflow an int64")
+	}
+	if err := v.parse("1EiB"); err != nil || ss.Field != 1<<60 {
+		t.Error("1EiB should fit in an int64")
+	}
+}
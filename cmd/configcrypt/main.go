@@ -0,0 +1,208 @@
+/*
+Configcrypt manages the encrypted values a config.Loader decrypts:
+
+	configcrypt genkey > config.key
+	configcrypt encrypt -key-file config.key 's3cret'    # or the value on stdin
+	configcrypt decrypt -key-file config.key 'enc:v1:...'
+	configcrypt rotate -old-key-file old.key -key-file new.key config.yaml ...
+
+Keys are base64, read from -key-file or the environment variable -key-env. rotate
+re-encrypts every enc:v1: value in the files with the new key, replacing each
+file once it is rewritten. Values already on the new key are accepted, so it
+can be rerun if it fails part way; give the Loader both keys (new first) while
+the files are being rotated.
+*/
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/heathedavid/config"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("configcrypt: ")
+
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+const usage = "Usage: configcrypt genkey | encrypt [value] | decrypt [value] | " +
+	"rotate -old-key-file file files..."
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	keyFile := fs.String("key-file", "", "File with the base64 key")
+	keyEnv := fs.String("key-env", "", "Environment variable with the base64 key")
+	oldKeyFile := fs.String("old-key-file", "",
+		"File with the base64 key to rotate from")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "genkey":
+		key, err := config.GenerateKey()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, base64.StdEncoding.EncodeToString(key))
+		return err
+
+	case "encrypt", "decrypt":
+		keys, err := readKeys(*keyFile, *keyEnv)
+		if err != nil {
+			return err
+		}
+		value, err := readValue(fs.Args(), stdin)
+		if err != nil {
+			return err
+		}
+		if args[0] == "encrypt" {
+			value, err = config.Encrypt(keys[0], value)
+		} else {
+			value, err = config.Decrypt(keys, value)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, value)
+		return err
+
+	case "rotate":
+		keys, err := readKeys(*keyFile, *keyEnv)
+		if err != nil {
+			return err
+		}
+		if *oldKeyFile == "" {
+			return fmt.Errorf("rotate needs -old-key-file")
+		}
+		old, err := readKeys(*oldKeyFile, "")
+		if err != nil {
+			return err
+		}
+		for _, file := range fs.Args() {
+			if err := rotate(file, old, keys[0]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.New(usage)
+}
+
+// The keys in file or the environment variable env.
+func readKeys(file, env string) ([][]byte, error) {
+	switch {
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := config.ParseKeys(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return keys, nil
+	case env != "":
+		keys, err := config.ParseKeys(os.Getenv(env))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", env, err)
+		}
+		return keys, nil
+	}
+	return nil, fmt.Errorf("No key, give -key-file or -key-env")
+}
+
+// The value given as the argument, or read from stdin without a trailing
+// newline.
+func readValue(args []string, stdin io.Reader) (string, error) {
+	switch len(args) {
+	case 0:
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case 1:
+		return args[0], nil
+	}
+	return "", fmt.Errorf("Only one value can be given")
+}
+
+var encrypted = regexp.MustCompile(`enc:v1:[A-Za-z0-9+/=]+`)
+
+// Re-encrypt the values in file encrypted with one of old, or key itself,
+// with key.
+func rotate(file string, old [][]byte, key []byte) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var rerr error
+	out := encrypted.ReplaceAllStringFunc(string(b), func(value string) string {
+		if rerr != nil {
+			return value
+		}
+		plaintext, err := config.Decrypt(append([][]byte{key}, old...), value)
+		if err != nil {
+			rerr = fmt.Errorf("%s: %v", file, err)
+			return value
+		}
+		value, rerr = config.Encrypt(key, plaintext)
+		return value
+	})
+	if rerr != nil {
+		return rerr
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	return replaceFile(file, []byte(out), info.Mode())
+}
+
+// Replace file with b by writing a temporary file beside it and renaming it
+// over file, so file is never left part written.
+func replaceFile(file string, b []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails once renamed
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
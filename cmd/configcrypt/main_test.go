@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runOutput(t *testing.T, stdin string, args ...string) string {
+	var out bytes.Buffer
+	if err := run(args, strings.NewReader(stdin), &out); err != nil {
+		t.Fatal(args, err)
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func writeKey(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(runOutput(t, "", "genkey")+"\n"),
+		0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := writeKey(t, t.TempDir(), "config.key")
+
+	value := runOutput(t, "hunter2\n", "encrypt", "-key-file", key)
+	if !strings.HasPrefix(value, "enc:v1:") {
+		t.Fatal("Unexpected encrypted value", value)
+	}
+	if v := runOutput(t, "", "decrypt", "-key-file", key, value); v != "hunter2" {
+		t.Error("Should decrypt to hunter2", v)
+	}
+
+	if err := run([]string{"encrypt", "x"}, nil, &bytes.Buffer{}); err == nil {
+		t.Error("No key should fail")
+	}
+	if err := run([]string{"frobnicate"}, nil, &bytes.Buffer{}); err == nil {
+		t.Error("An unknown command should fail")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	old, key := writeKey(t, dir, "old.key"), writeKey(t, dir, "new.key")
+
+	a := runOutput(t, "", "encrypt", "-key-file", old, "hunter2")
+	b := runOutput(t, "", "encrypt", "-key-file", old, "s3cret")
+	path := filepath.Join(dir, "config.json")
+	doc := `{"password": "` + a + `", "token": "` + b + `", "port": 80}`
+	if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runOutput(t, "", "rotate", "-old-key-file", old, "-key-file", key, path)
+
+	rotated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := encrypted.FindAllString(string(rotated), -1)
+	if len(values) != 2 || !strings.Contains(string(rotated), `"port": 80`) {
+		t.Fatal("Unexpected rotated file", string(rotated))
+	}
+	if v := runOutput(t, "", "decrypt", "-key-file", key, values[0]); v != "hunter2" {
+		t.Error("Unexpected password", v)
+	}
+	if v := runOutput(t, "", "decrypt", "-key-file", key, values[1]); v != "s3cret" {
+		t.Error("Unexpected token", v)
+	}
+	if err := run([]string{"decrypt", "-key-file", old, values[0]}, nil,
+		&bytes.Buffer{}); err == nil {
+		t.Error("The old key should no longer decrypt")
+	}
+
+	// Rerunning on a partly rotated file re-encrypts the rest
+	c := runOutput(t, "", "encrypt", "-key-file", old, "pepper")
+	doc = string(rotated) + `{"salt": "` + c + `"}`
+	if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runOutput(t, "", "rotate", "-old-key-file", old, "-key-file", key, path)
+	rotated, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values = encrypted.FindAllString(string(rotated), -1)
+	if len(values) != 3 {
+		t.Fatal("Unexpected rotated file", string(rotated))
+	}
+	if v := runOutput(t, "", "decrypt", "-key-file", key, values[2]); v != "pepper" {
+		t.Error("Unexpected salt", v)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Error("The temporary file should be gone", entries)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode() != 0600 {
+		t.Error("The file's mode should be kept", info, err)
+	}
+}
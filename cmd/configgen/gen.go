@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heathedavid/config"
+)
+
+// A configuration field of a struct as the reflective loader plans it.
+type genField struct {
+	name       string   // Go field name, dotted if nested
+	path       []string // env names of the field and its parents
+	tag        string
+	typ        string // the field's type as written, e.g. time.Duration
+	expr       string // the field relative to cfg, e.g. cfg.DB.Host
+	unexported bool
+}
+
+// The package being generated for.
+type genPackage struct {
+	name    string
+	structs map[string]*ast.StructType
+}
+
+// Generate the source of the LoadXxx functions for the named struct types
+// (all those with env_ tags if none) of the package in dir, ignoring the file
+// output.
+func generate(dir string, names []string, output string) ([]byte, error) {
+	pkg, err := parsePackage(dir, output)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		for name, st := range pkg.structs {
+			if hasEnvTag(st) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("No struct types with env_ tags in %s", dir)
+	}
+
+	var body bytes.Buffer
+	usesTime := false
+	for _, name := range names {
+		st, ok := pkg.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("No struct type %s in %s", name, dir)
+		}
+
+		var fields []genField
+		if err := pkg.walk(st, "", nil, "cfg", &fields); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if writeLoader(&body, name, fields) {
+			usesTime = true
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by configgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg.name)
+	fmt.Fprintf(&b, "import (\n")
+	if usesTime {
+		fmt.Fprintf(&b, "\"time\"\n\n")
+	}
+	fmt.Fprintf(&b, "\"github.com/heathedavid/config\"\n)\n")
+	b.Write(body.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// Parse the non-test Go files in dir other than output.
+func parsePackage(dir, output string) (*genPackage, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &genPackage{structs: make(map[string]*ast.StructType)}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		base := filepath.Base(file)
+		if base == output || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = f.Name.Name
+		}
+
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					pkg.structs[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("No Go files in %s", dir)
+	}
+	return pkg, nil
+}
+
+// Add the configuration fields of st, which is at goPath, path and expr, to
+// fields in the order the reflective loader registers them.
+func (pkg *genPackage) walk(st *ast.StructType, goPath string, path []string,
+	expr string, fields *[]genField) error {
+
+	for _, f := range st.Fields.List {
+		var tag string
+		if f.Tag != nil {
+			var err error
+			if tag, err = strconv.Unquote(f.Tag.Value); err != nil {
+				return err
+			}
+		}
+		stag := reflect.StructTag(tag)
+
+		names := f.Names
+		anonymous := len(names) == 0
+		if anonymous {
+			names = []*ast.Ident{{Name: embeddedName(f.Type)}}
+		}
+
+		for _, ident := range names {
+			if _, ok := stag.Lookup("env_no"); ok {
+				continue
+			}
+
+			name, ok := stag.Lookup("env_name")
+			if !ok {
+				name = ident.Name
+			}
+			fieldPath := append(append([]string(nil), path...), name)
+			fieldGoPath := ident.Name
+			if goPath != "" {
+				fieldGoPath = goPath + "." + fieldGoPath
+			}
+			fieldExpr := expr + "." + ident.Name
+
+			nested := pkg.nested(f.Type)
+			if !ast.IsExported(ident.Name) && !(anonymous && nested != nil) {
+				*fields = append(*fields, genField{name: fieldGoPath,
+					path: fieldPath, unexported: true})
+				continue
+			}
+
+			if nested != nil {
+				if err := pkg.walk(nested, fieldGoPath, fieldPath, fieldExpr,
+					fields); err != nil {
+					return err
+				}
+				continue
+			}
+
+			*fields = append(*fields, genField{name: fieldGoPath,
+				path: fieldPath, tag: tag, typ: typeString(f.Type),
+				expr: fieldExpr})
+		}
+	}
+	return nil
+}
+
+// The struct type of a nested struct field of type t, nil if it is not one.
+func (pkg *genPackage) nested(t ast.Expr) *ast.StructType {
+	switch x := t.(type) {
+	case *ast.StructType:
+		return x
+	case *ast.Ident:
+		return pkg.structs[x.Name]
+	}
+	return nil
+}
+
+// The type t as written, "" if it is not a (pointer to a) named type.
+func typeString(t ast.Expr) string {
+	switch x := t.(type) {
+	case *ast.StarExpr:
+		if s := typeString(x.X); s != "" {
+			return "*" + s
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok {
+			return pkg.Name + "." + x.Sel.Name
+		}
+	case *ast.Ident:
+		return x.Name
+	}
+	return ""
+}
+
+// The name of an embedded field of type t.
+func embeddedName(t ast.Expr) string {
+	switch x := t.(type) {
+	case *ast.StarExpr:
+		return embeddedName(x.X)
+	case *ast.SelectorExpr:
+		return x.Sel.Name
+	case *ast.Ident:
+		return x.Name
+	}
+	return ""
+}
+
+func hasEnvTag(st *ast.StructType) bool {
+	for _, f := range st.Fields.List {
+		if f.Tag != nil && strings.Contains(f.Tag.Value, "env_") {
+			return true
+		}
+	}
+	return false
+}
+
+// Write the Plan and LoadXxx function of the struct type name, returning
+// whether the code uses the time package.
+func writeLoader(b *bytes.Buffer, name string, fields []genField) bool {
+	plan := "plan" + name
+	usesTime := false
+
+	fmt.Fprintf(b, "\nvar %s = config.NewPlan(%q, []config.PlanField{\n",
+		plan, name)
+	for _, f := range fields {
+		fmt.Fprintf(b, "{Name: %q, Path: %#v", f.name, f.path)
+		if f.unexported {
+			fmt.Fprintf(b, ", Unexported: true},\n")
+			continue
+		}
+
+		tag := reflect.StructTag(f.tag)
+		if keys := envTags(f.tag); len(keys) > 0 {
+			fmt.Fprintf(b, ", Tags: map[string]string{")
+			for i, k := range keys {
+				if i > 0 {
+					fmt.Fprintf(b, ", ")
+				}
+				fmt.Fprintf(b, "%q: %q", k, tag.Get(k))
+			}
+			fmt.Fprintf(b, "}")
+		}
+		if def := defaultExpr(f.typ, tag); def != "" {
+			fmt.Fprintf(b, ", Default: %s", def)
+			if strings.Contains(f.typ, "time.") {
+				usesTime = true
+			}
+		}
+		fmt.Fprintf(b, "},\n")
+	}
+	fmt.Fprintf(b, "})\n")
+
+	fmt.Fprintf(b, "\n// Load%s loads cfg as config.Load does, without "+
+		"reflecting on %s.\n", name, name)
+	fmt.Fprintf(b, "func Load%s(cfg *%s, opts ...config.Option) error {\n",
+		name, name)
+	fmt.Fprintf(b, "l := config.NewLoader(opts...)\n")
+	fmt.Fprintf(b, "if cfg == nil {\nreturn l.LoadPlan(cfg, %s, nil)\n}\n",
+		plan)
+	fmt.Fprintf(b, "return l.LoadPlan(cfg, %s, []interface{}{\n", plan)
+	for _, f := range fields {
+		if f.unexported {
+			fmt.Fprintf(b, "nil, // %s\n", f.name)
+		} else {
+			fmt.Fprintf(b, "&%s,\n", f.expr)
+		}
+	}
+	fmt.Fprintf(b, "})\n}\n")
+	return usesTime
+}
+
+// The names of the env_ tags in tag, in order, as reflect.StructTag parses
+// them.
+func envTags(tag string) []string {
+	var keys []string
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' &&
+			tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		name := tag[:i]
+		tag = tag[i+1:]
+
+		// The quoted value
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		tag = tag[i+1:]
+
+		if strings.HasPrefix(name, "env_") && !contains(keys, name) {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// The Go expression of the env_def default of a field of type typ, parsed as
+// the Loader parses it. "" if there is none or it is left to the Loader: if
+// it is encrypted, does not parse (for the Loader to report) or its value has
+// no literal.
+func defaultExpr(typ string, tag reflect.StructTag) string {
+	def, ok := tag.Lookup("env_def")
+	if !ok || config.IsEncrypted(def) {
+		return ""
+	}
+
+	if tag.Get("env_unit") == "bytes" {
+		switch typ {
+		case "int", "int64", "uint64":
+			if b, err := config.ParseByteSize(def); err == nil {
+				return fmt.Sprintf("config.ByteSize(%d)", uint64(b))
+			}
+		}
+		return ""
+	}
+
+	switch typ {
+	case "string":
+		return strconv.Quote(def)
+	case "int":
+		if i, err := strconv.Atoi(def); err == nil {
+			return strconv.Itoa(i)
+		}
+	case "int64":
+		if i, err := strconv.ParseInt(def, 0, 64); err == nil {
+			return fmt.Sprintf("int64(%d)", i)
+		}
+	case "uint64":
+		if u, err := strconv.ParseUint(def, 0, 64); err == nil {
+			return fmt.Sprintf("uint64(%d)", u)
+		}
+	case "float64":
+		f, err := strconv.ParseFloat(def, 64)
+		if err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return "float64(" + strconv.FormatFloat(f, 'g', -1, 64) + ")"
+		}
+	case "bool":
+		if v, err := strconv.ParseBool(def); err == nil {
+			return strconv.FormatBool(v)
+		}
+	case "time.Duration":
+		if d, err := config.ParseDuration(def); err == nil {
+			return fmt.Sprintf("time.Duration(%d)", int64(d))
+		}
+	case "config.ByteSize":
+		if b, err := config.ParseByteSize(def); err == nil {
+			return fmt.Sprintf("config.ByteSize(%d)", uint64(b))
+		}
+	case "time.Weekday":
+		if d, err := config.ParseWeekday(def); err == nil {
+			return "time." + d.String()
+		}
+	case "time.Time":
+		t, err := config.ParseTime(def, tag.Get("env_layout"))
+		if err == nil && t.Location() == time.UTC {
+			return fmt.Sprintf("time.Date(%d, time.%s, %d, %d, %d, %d, %d, "+
+				"time.UTC)", t.Year(), t.Month(), t.Day(), t.Hour(),
+				t.Minute(), t.Second(), t.Nanosecond())
+		}
+	case "*time.Location":
+		if def == "UTC" {
+			return "time.UTC"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The committed generated code of the parity package is up to date.
+func TestGenerateParity(t *testing.T) {
+	dir := filepath.Join("internal", "parity")
+	want, err := os.ReadFile(filepath.Join(dir, "config_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := generate(dir, []string{"Server", "Broken", "Inline"},
+		"config_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Error("config_gen.go is out of date, run go generate")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := filepath.Join("internal", "parity")
+	if _, err := generate(dir, []string{"Missing"}, "config_gen.go"); err == nil {
+		t.Error("Expected an error for a missing type")
+	}
+	if _, err := generate("testdata/none", nil, "config_gen.go"); err == nil {
+		t.Error("Expected an error for a directory without Go files")
+	}
+}
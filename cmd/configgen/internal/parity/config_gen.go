@@ -0,0 +1,98 @@
+// Code generated by configgen. DO NOT EDIT.
+
+package parity
+
+import (
+	"time"
+
+	"github.com/heathedavid/config"
+)
+
+var planServer = config.NewPlan("Server", []config.PlanField{
+	{Name: "Name", Path: []string{"name"}, Tags: map[string]string{"env_name": "name", "env_def": "server", "env_desc": "Server name"}, Default: "server"},
+	{Name: "Port", Path: []string{"port"}, Tags: map[string]string{"env_name": "port", "env_def": "8080", "env_short": "p"}, Default: 8080},
+	{Name: "Verbose", Path: []string{"verbose"}, Tags: map[string]string{"env_name": "verbose", "env_short": "v", "env_count": ""}},
+	{Name: "Debug", Path: []string{"debug"}, Tags: map[string]string{"env_name": "debug", "env_def": "true"}, Default: true},
+	{Name: "Ratio", Path: []string{"ratio"}, Tags: map[string]string{"env_name": "ratio", "env_def": "0.25"}, Default: float64(0.25)},
+	{Name: "Timeout", Path: []string{"timeout"}, Tags: map[string]string{"env_name": "timeout", "env_def": "1d12h"}, Default: time.Duration(129600000000000)},
+	{Name: "Cache", Path: []string{"cache"}, Tags: map[string]string{"env_name": "cache", "env_def": "64MiB"}, Default: config.ByteSize(67108864)},
+	{Name: "Buffer", Path: []string{"buffer"}, Tags: map[string]string{"env_name": "buffer", "env_def": "4k", "env_unit": "bytes"}, Default: config.ByteSize(4096)},
+	{Name: "Start", Path: []string{"start"}, Tags: map[string]string{"env_name": "start", "env_layout": "2006-01-02", "env_def": "2017-06-01"}, Default: time.Date(2017, time.June, 1, 0, 0, 0, 0, time.UTC)},
+	{Name: "Zone", Path: []string{"zone"}, Tags: map[string]string{"env_name": "zone", "env_def": "UTC"}, Default: time.UTC},
+	{Name: "Day", Path: []string{"day"}, Tags: map[string]string{"env_name": "day", "env_def": "mon"}, Default: time.Monday},
+	{Name: "URL", Path: []string{"url"}, Tags: map[string]string{"env_name": "url", "env_alias": "ADDR", "env_deprecated": "use url"}},
+	{Name: "Password", Path: []string{"password"}, Tags: map[string]string{"env_name": "password", "env_secret": ""}},
+	{Name: "Count", Path: []string{"count"}, Tags: map[string]string{"env_name": "count"}},
+	{Name: "Offset", Path: []string{"offset"}, Tags: map[string]string{"env_name": "offset", "env_def": "-1"}, Default: int64(-1)},
+	{Name: "DB.Host", Path: []string{"db", "host"}, Tags: map[string]string{"env_name": "host", "env_def": "localhost"}, Default: "localhost"},
+	{Name: "DB.Port", Path: []string{"db", "port"}, Tags: map[string]string{"env_name": "port", "env_def": "5432"}, Default: 5432},
+	{Name: "limits.Rate", Path: []string{"limits", "rate"}, Tags: map[string]string{"env_name": "rate", "env_def": "100"}, Default: 100},
+	{Name: "limits.Burst", Path: []string{"limits", "burst"}, Tags: map[string]string{"env_name": "burst"}},
+})
+
+// LoadServer loads cfg as config.Load does, without reflecting on Server.
+func LoadServer(cfg *Server, opts ...config.Option) error {
+	l := config.NewLoader(opts...)
+	if cfg == nil {
+		return l.LoadPlan(cfg, planServer, nil)
+	}
+	return l.LoadPlan(cfg, planServer, []interface{}{
+		&cfg.Name,
+		&cfg.Port,
+		&cfg.Verbose,
+		&cfg.Debug,
+		&cfg.Ratio,
+		&cfg.Timeout,
+		&cfg.Cache,
+		&cfg.Buffer,
+		&cfg.Start,
+		&cfg.Zone,
+		&cfg.Day,
+		&cfg.URL,
+		&cfg.Password,
+		&cfg.Count,
+		&cfg.Offset,
+		&cfg.DB.Host,
+		&cfg.DB.Port,
+		&cfg.limits.Rate,
+		&cfg.limits.Burst,
+	})
+}
+
+var planBroken = config.NewPlan("Broken", []config.PlanField{
+	{Name: "Port", Path: []string{"port"}, Tags: map[string]string{"env_name": "port", "env_def": "eighty"}},
+	{Name: "Size", Path: []string{"Size"}, Tags: map[string]string{"env_unit": "bytes"}},
+	{Name: "Short", Path: []string{"Short"}, Tags: map[string]string{"env_short": "xy"}},
+	{Name: "hidden", Path: []string{"hidden"}, Unexported: true},
+	{Name: "Channel", Path: []string{"Channel"}},
+})
+
+// LoadBroken loads cfg as config.Load does, without reflecting on Broken.
+func LoadBroken(cfg *Broken, opts ...config.Option) error {
+	l := config.NewLoader(opts...)
+	if cfg == nil {
+		return l.LoadPlan(cfg, planBroken, nil)
+	}
+	return l.LoadPlan(cfg, planBroken, []interface{}{
+		&cfg.Port,
+		&cfg.Size,
+		&cfg.Short,
+		nil, // hidden
+		&cfg.Channel,
+	})
+}
+
+var planInline = config.NewPlan("Inline", []config.PlanField{
+	{Name: "Opts.Level", Path: []string{"opts", "level"}, Tags: map[string]string{"env_name": "level", "env_def": "info"}, Default: "info"},
+})
+
+// LoadInline loads cfg as config.Load does, without reflecting on Inline.
+func LoadInline(cfg *Inline, opts ...config.Option) error {
+	l := config.NewLoader(opts...)
+	if cfg == nil {
+		return l.LoadPlan(cfg, planInline, nil)
+	}
+	return l.LoadPlan(cfg, planInline, []interface{}{
+		&cfg.Opts.Level,
+	})
+}
@@ -0,0 +1,146 @@
+package parity
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/heathedavid/config"
+)
+
+// A load's outcome, compared between the reflective and generated loaders.
+type outcome struct {
+	strct interface{}
+	err   string
+	help  string
+}
+
+// Load strct with config.Load if fn is nil, else with fn.
+func load(args []string, env map[string]string, strct interface{},
+	fn func(opts ...config.Option) error) outcome {
+
+	var help bytes.Buffer
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&help)
+	opts := []config.Option{config.WithFlagSet(fs, args),
+		config.WithEnvMap(env), config.WithLogger(discard{})}
+
+	var err error
+	if fn == nil {
+		err = config.NewLoader(opts...).Load(strct)
+	} else {
+		err = fn(opts...)
+	}
+
+	o := outcome{strct: strct}
+	if err != nil {
+		o.err = err.Error()
+	}
+	fs.PrintDefaults()
+	o.help = help.String()
+	return o
+}
+
+type discard struct{}
+
+func (discard) Printf(format string, v ...interface{}) {}
+
+var cases = []struct {
+	args []string
+	env  map[string]string
+}{
+	{nil, nil},
+	{[]string{"-p", "9090", "-v", "-v", "-no-debug"}, nil},
+	{[]string{"-timeout", "P1DT2H", "-cache", "1.5GiB", "-buffer", "1MB"},
+		map[string]string{"zone": "Europe/London", "day": "fri"}},
+	{nil, map[string]string{"ADDR": "http://old", "db_host": "db",
+		"limits_rate": "10", "limits_burst": "20", "password": "s3cr3t"}},
+	{nil, map[string]string{"limits_burst": "5"}},
+	{nil, map[string]string{"port": "eighty", "start": "yesterday"}},
+	{[]string{"-count", "-1"}, nil},
+	{[]string{"-nope"}, nil},
+}
+
+func TestServerParity(t *testing.T) {
+	for _, c := range cases {
+		want := load(c.args, c.env, &Server{}, nil)
+
+		var got Server
+		o := load(c.args, c.env, &got, func(opts ...config.Option) error {
+			return LoadServer(&got, opts...)
+		})
+
+		if !reflect.DeepEqual(want.strct, o.strct) {
+			t.Errorf("%v %v: Load gave %+v, LoadServer %+v", c.args, c.env,
+				want.strct, o.strct)
+		}
+		if want.err != o.err {
+			t.Errorf("%v %v: Load returned %q, LoadServer %q", c.args, c.env,
+				want.err, o.err)
+		}
+		if want.help != o.help {
+			t.Errorf("Help differs:\n%s\n%s", want.help, o.help)
+		}
+	}
+}
+
+func TestErrorParity(t *testing.T) {
+	var b Broken
+	want := load(nil, nil, &Broken{}, nil)
+	got := load(nil, nil, &b, func(opts ...config.Option) error {
+		return LoadBroken(&b, opts...)
+	})
+	if want.err == "" || want.err != got.err {
+		t.Errorf("Load returned %q, LoadBroken %q", want.err, got.err)
+	}
+
+	want = load(nil, nil, (*Server)(nil), nil)
+	got = load(nil, nil, nil, func(opts ...config.Option) error {
+		return LoadServer(nil, opts...)
+	})
+	if want.err == "" || want.err != got.err {
+		t.Errorf("Load returned %q, LoadServer %q", want.err, got.err)
+	}
+}
+
+func TestInlineParity(t *testing.T) {
+	var got Inline
+	want := load([]string{"-opts_level", "debug"}, nil, &Inline{}, nil)
+	load([]string{"-opts_level", "debug"}, nil, &got,
+		func(opts ...config.Option) error {
+			return LoadInline(&got, opts...)
+		})
+	if !reflect.DeepEqual(want.strct, &got) || got.Opts.Level != "debug" {
+		t.Error("Unexpected", want.strct, got)
+	}
+}
+
+// Load a Server with fn. The generated LoadServer saves reflecting on the
+// type, which config.Load only does once per type, not allocations: both
+// create the same flags and Values.
+func benchmarkLoad(b *testing.B, fn func(cfg *Server,
+	opts ...config.Option) error) {
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var cfg Server
+		fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+		err := fn(&cfg, config.WithFlagSet(fs, []string{"-p", "9090"}),
+			config.WithEnvMap(map[string]string{"db_host": "db"}),
+			config.WithLogger(discard{}))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	benchmarkLoad(b, func(cfg *Server, opts ...config.Option) error {
+		return config.NewLoader(opts...).Load(cfg)
+	})
+}
+
+func BenchmarkLoadServer(b *testing.B) {
+	benchmarkLoad(b, LoadServer)
+}
@@ -0,0 +1,72 @@
+/*
+Package parity has configuration structs loaded both by config.Load and by the
+loaders configgen generates for them, to check they behave the same.
+*/
+package parity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/heathedavid/config"
+)
+
+//go:generate go run ../.. -type Server,Broken,Inline
+
+type Server struct {
+	Name     string          `env_name:"name" env_def:"server" env_desc:"Server name"`
+	Port     int             `env_name:"port" env_def:"8080" env_short:"p"`
+	Verbose  int             `env_name:"verbose" env_short:"v" env_count:""`
+	Debug    bool            `env_name:"debug" env_def:"true"`
+	Ratio    float64         `env_name:"ratio" env_def:"0.25"`
+	Timeout  time.Duration   `env_name:"timeout" env_def:"1d12h"`
+	Cache    config.ByteSize `env_name:"cache" env_def:"64MiB"`
+	Buffer   int             `env_name:"buffer" env_def:"4k" env_unit:"bytes"`
+	Start    time.Time       `env_name:"start" env_layout:"2006-01-02" env_def:"2017-06-01"`
+	Zone     *time.Location  `env_name:"zone" env_def:"UTC"`
+	Day      time.Weekday    `env_name:"day" env_def:"mon"`
+	URL      string          `env_name:"url" env_alias:"ADDR" env_deprecated:"use url"`
+	Password string          `env_name:"password" env_secret:""`
+	Count    uint64          `env_name:"count"`
+	Offset   int64           `env_name:"offset" env_def:"-1"`
+	DB       Database        `env_name:"db"`
+	limits
+	Computed string `env_no:""`
+}
+
+type Database struct {
+	Host string `env_name:"host" env_def:"localhost"`
+	Port int    `env_name:"port" env_def:"5432"`
+}
+
+type limits struct {
+	Rate  int `env_name:"rate" env_def:"100"`
+	Burst int `env_name:"burst"`
+}
+
+var errRate = errors.New("burst below rate")
+
+func (s *Server) Validate() error {
+	if s.Burst != 0 && s.Burst < s.Rate {
+		return errRate
+	}
+	return nil
+}
+
+func (s *Server) Initialize() {
+	s.Computed = s.Name + ":" + s.DB.Host
+}
+
+type Broken struct {
+	Port    int    `env_name:"port" env_def:"eighty"`
+	Size    string `env_unit:"bytes"`
+	Short   int    `env_short:"xy"`
+	hidden  string
+	Channel chan int
+}
+
+type Inline struct {
+	Opts struct {
+		Level string `env_name:"level" env_def:"info"`
+	} `env_name:"opts"`
+}
@@ -0,0 +1,67 @@
+/*
+Configgen generates a LoadXxx function for each configuration struct type Xxx
+in a package, loading it as config.Load does but without reflecting on the
+struct type or its tags at runtime. The fields are set through typed pointers
+and their env_def defaults parsed when generating:
+
+	//go:generate configgen -type Server,Worker
+
+	func LoadServer(cfg *Server, opts ...config.Option) error
+
+The generated code reads the same env_* tags. Nested structs must be declared
+in the same package (or inline) to be recognised as nested, structs from other
+packages other than time.Time and config.FeatureFlag are treated as unsupported
+field types. Slices and maps of structs, and their elements, are loaded by
+reflection, as are defaults which are encrypted or have no Go literal (a
+FeatureFlag or a time.Time not in UTC).
+
+Without -type a function is generated for every struct type with an env_ tag.
+The output is written to config_gen.go in the package directory unless -output
+is given.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("configgen: ")
+
+	types := flag.String("type", "", "Comma separated struct type names")
+	output := flag.String("output", "config_gen.go", "Output file name")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: configgen [flags] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+
+	out := *output
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(dir, out)
+	}
+
+	src, err := generate(dir, names, filepath.Base(out))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -2,12 +2,48 @@
 config is used to initialize configuration structures from the struct field tags
 or environmental variables.
 
-env_name - if defined will be the name of the environmental var (else field name)
-env_def  - if defined is used for a string representation of the default value
- 					 if not defined and the environment variable is not defined the
-					 zero value for the field will be used.
-env_desc - A description of the environmental var
-env_no	 - Mark a field as a non-configuration field (generally initialized)
+	env_name - if defined will be the name of the environmental var (else field name)
+	env_def - if defined is used for a string representation of the default value,
+	  if not defined and the environment variable is not defined the zero value for
+	  the field will be used.
+	env_def_func - The name of a function registered with RegisterDefault which
+	  computes the default
+	env_desc - A description of the environmental var
+	env_alias - Comma separated additional names for the flag/env var
+	env_deprecated - The field's aliases (or the field if it has none) are
+	  deprecated, using them logs this message
+	env_count - An int field counting the times the flag is given (-v -v)
+	env_short - A single character short name for the flag (-p)
+	env_no - Mark a field as a non-configuration field (generally initialized)
+	env_layout - time.Time layout, RFC 3339 if not defined (Unix seconds always
+	  accepted)
+	env_unit - "bytes" parses an int or uint64 field as a ByteSize (100MiB)
+	env_secret - The value is never shown, e.g. by an AdminHandler
+	env_required_if, env_excludes, env_lt, env_lte, env_gt, env_gte - Constraints
+	  on other fields of the struct (see ConstraintError)
+	env_mutable - The field can be changed at runtime by an AdminHandler
+
+Nested structs are configured too, their fields' names are prefixed with the
+name of the struct field (DB_Host). Slices of structs are configured by index
+(UPSTREAM_0_HOST, -upstream.0.host or an array in a file), maps of structs by
+name (BACKEND_PRIMARY_URL or an object in a file).
+
+A FeatureFlag field is on, off or rolled out to a percentage of subjects, or
+to listed ones, and checked per subject with Enabled.
+
+Each field is set from the first Source with a value for its name, by default
+the flag, then the environmental var, then env_def. A Loader can be given a
+different order or other Sources such as FileSource and HTTPSource. Values
+from any Source may be enc:v1: values encrypted by cmd/configcrypt, decrypted
+with the keys given by WithKeys, WithKeyFile or WithKeyEnv.
+
+A Store shares the configuration between goroutines, Track keeps it up to date
+as the Loader reloads and Subscribe reports each change. Log and WithExpvar
+report the configuration through log/slog and expvar with secrets redacted.
+
+cmd/configgen generates LoadXxx functions which load a struct type from a
+Plan, setting its fields through typed pointers with their tags and defaults
+worked out when generating. Only slices and maps of structs are reflected on.
 */
 package config
 
@@ -34,222 +70,295 @@ type Initialize interface {
 	Initialize()
 }
 
-type setters struct {
-	s []SetValue
-}
-
-// Pass a point to the annotated structures you want to initialize
+// Pass a point to the annotated structures you want to initialize. The fields
+// are set from the command line flags, the environment and their defaults.
 func Load(structs ...interface{}) error {
-	if flag.Parsed() {
-		return fmt.Errorf("Load must be called before a call to flag.Pars")
-	}
-
-	m := make(map[string]ConfigFlag)
-
-	numStructs := len(structs)
-
-	fieldSetters := make([]setters, numStructs)
-
-	for i := 0; i < numStructs; i++ {
-		s := structs[i]
-
-		t := reflect.ValueOf(s).Elem()
-		typeOfT := t.Type()
-
-		fieldSetters[i].s = make([]SetValue, t.NumField())
-
-		for j := 0; j < t.NumField(); j++ {
-			f := t.Field(j)
-
-			tag := typeOfT.Field(j).Tag
-
-			var ok bool
-			if _, ok = tag.Lookup("env_no"); ok {
-				continue
-			} // Not a configuration field
-
-			var name string
-			if name, ok = tag.Lookup("env_name"); !ok {
-				name = typeOfT.Field(i).Name
-			}
-
-			defVal, isDefVal := tag.Lookup("env_def")
+	return NewLoader().Load(structs...)
+}
 
-			var desc string
-			if desc, ok = tag.Lookup("env_desc"); !ok {
-				desc = name
-			}
+var stringType = reflect.TypeOf((*string)(nil)).Elem()
+var intType = reflect.TypeOf((*int)(nil)).Elem()
+var int64Type = reflect.TypeOf((*int64)(nil)).Elem()
+var uint64Type = reflect.TypeOf((*uint64)(nil)).Elem()
+var float64Type = reflect.TypeOf((*float64)(nil)).Elem()
+var boolType = reflect.TypeOf((*bool)(nil)).Elem()
+var durationType = reflect.TypeOf((*time.Duration)(nil)).Elem()
+var byteSizeType = reflect.TypeOf((*ByteSize)(nil)).Elem()
+var timeType = reflect.TypeOf((*time.Time)(nil)).Elem()
+var locationType = reflect.TypeOf((*time.Location)(nil))
+var weekdayType = reflect.TypeOf((*time.Weekday)(nil)).Elem()
+var featureFlagType = reflect.TypeOf((*FeatureFlag)(nil)).Elem()
 
-			var err error
-			fieldSetters[i].s[j], err = parseDefault(m, f, name, defVal, desc, isDefVal)
+func parseDefault(m map[string]ConfigFlag, t reflect.Value, name, defVal,
+	desc string, isDefVal bool) (SetValue, error) {
+	return registerFlag(flag.CommandLine, m, t, "", name, defVal, desc,
+		isDefVal)
+}
 
-			if err != nil {
-				return nil
-			}
+// Create the Value for field t, registering a flag for name with fs unless
+// one is already in m. The flag holds the default so it is shown in the help.
+func registerFlag(fs *flag.FlagSet, m map[string]ConfigFlag, t reflect.Value,
+	tag reflect.StructTag, name, defVal, desc string,
+	isDefVal bool) (SetValue, error) {
+
+	p := t.Addr().Interface()
+	vt := parseValueTags(tag)
+	var def interface{}
+	if isDefVal {
+		var err error
+		if def, err = parseDefVal(p, vt, defVal); err != nil {
+			return nil, err
 		}
 	}
+	return newValue(fs, m, p, vt, name, def, desc)
+}
 
-	flag.Parse()
-	for i := 0; i < numStructs; i++ {
-		for j := 0; j < len(fieldSetters[i].s); j++ {
-			if fieldSetters[i].s[j] != nil {
-				if err := fieldSetters[i].s[j].Set(); err != nil {
-					return err
-				}
-			}
-		}
+// The tags which change how a field's value is parsed.
+type valueTags struct {
+	bytes  bool   // env_unit:"bytes"
+	count  bool   // env_count
+	layout string // env_layout
+}
 
-		if v, ok := structs[i].(Validate); ok {
-			if err := v.Validate(); err != nil {
-				return err
-			}
-		}
+func parseValueTags(tag fieldTags) valueTags {
+	_, count := tag.Lookup("env_count")
+	return valueTags{
+		bytes:  tag.Get("env_unit") == "bytes",
+		count:  count,
+		layout: tag.Get("env_layout"),
+	}
+}
 
-		if v, ok := structs[i].(Initialize); ok {
-			v.Initialize()
+// Parse s, the default of the field p points to. Nil if the field's type is
+// not supported, which newValue reports.
+func parseDefVal(p interface{}, vt valueTags, s string) (interface{}, error) {
+	if vt.bytes {
+		switch p.(type) {
+		case *int, *int64, *uint64:
+			return ParseByteSize(s)
 		}
+		return nil, nil
 	}
 
-	return nil
+	switch p.(type) {
+	case *string:
+		return s, nil
+	case *int:
+		return strconv.Atoi(s)
+	case *int64:
+		return strconv.ParseInt(s, 0, 64)
+	case *uint64:
+		return strconv.ParseUint(s, 0, 64)
+	case *float64:
+		return strconv.ParseFloat(s, 64)
+	case *bool:
+		return strconv.ParseBool(s)
+	case *ByteSize:
+		return ParseByteSize(s)
+	case *time.Time:
+		return ParseTime(s, vt.layout)
+	case **time.Location:
+		return time.LoadLocation(s)
+	case *time.Weekday:
+		return ParseWeekday(s)
+	case *time.Duration:
+		return ParseDuration(s)
+	case *FeatureFlag:
+		return ParseFeatureFlag(s)
+	}
+	return nil, nil
 }
 
-var stringType = reflect.TypeOf((*string)(nil)).Elem()
-var intType = reflect.TypeOf((*int)(nil)).Elem()
-var int64Type = reflect.TypeOf((*int64)(nil)).Elem()
-var uint64Type = reflect.TypeOf((*uint64)(nil)).Elem()
-var float64Type = reflect.TypeOf((*float64)(nil)).Elem()
-var boolType = reflect.TypeOf((*bool)(nil)).Elem()
-var durationType = reflect.TypeOf((*time.Duration)(nil)).Elem()
+// Create the Value for the field p points to with the default d (nil if there
+// is none), registering a flag for name with fs unless one is already in m.
+func newValue(fs *flag.FlagSet, m map[string]ConfigFlag, p interface{},
+	vt valueTags, name string, d interface{}, desc string) (SetValue, error) {
 
-func parseDefault(m map[string]ConfigFlag, t reflect.Value, name, defVal,
-	desc string, isDefVal bool) (SetValue, error) {
+	isDefVal := d != nil
 
-	var err error
-	switch t.Type() { // I think I could use t.Interface().(type)
-	case stringType:
-		var def string
-		if isDefVal {
-			def = defVal
+	if vt.bytes {
+		switch p.(type) {
+		case *int, *int64, *uint64:
+			return newByteSizeValue(fs, m, p, name, d, desc)
 		}
+		return nil, &TagError{Tag: "env_unit", Value: "bytes",
+			Err: fmt.Errorf("Unsupported type %s", reflect.TypeOf(p).Elem())}
+	}
+
+	switch t := p.(type) {
+	case *string:
+		def, _ := d.(string)
 		if cf, ok := m[name]; ok {
 			val := StringValue{name, isDefVal, def, cf, t}
 			return &val, nil
 		} else {
-			cf := StringFlag{}
+			cf := StringFlag{value: def}
 			m[name] = &cf
-			flag.Var(&cf, name, desc)
+			fs.Var(&cf, name, desc)
 			val := StringValue{name, isDefVal, def, &cf, t}
 			return &val, nil
 		}
 
-	case intType:
-		var def int
-		if isDefVal {
-			if def, err = strconv.Atoi(defVal); err != nil {
-				return nil, err
-			}
-		}
+	case *int:
+		def, _ := d.(int)
 		if cf, ok := m[name]; ok {
 			val := IntValue{name, isDefVal, def, cf, t}
 			return &val, nil
+		} else if vt.count {
+			cf := CountFlag{value: def}
+			m[name] = &cf
+			fs.Var(&cf, name, desc)
+			val := IntValue{name, isDefVal, def, &cf, t}
+			return &val, nil
 		} else {
-			cf := IntFlag{}
+			cf := IntFlag{value: def}
 			m[name] = &cf
-			flag.Var(&cf, name, desc)
+			fs.Var(&cf, name, desc)
 			val := IntValue{name, isDefVal, def, &cf, t}
 			return &val, nil
 		}
 
-	case int64Type:
-		var def int64
-		if isDefVal {
-			if def, err = strconv.ParseInt(defVal, 0, 64); err != nil {
-				return nil, err
-			}
-		}
+	case *int64:
+		def, _ := d.(int64)
 		if cf, ok := m[name]; ok {
 			val := Int64Value{name, isDefVal, def, cf, t}
 			return &val, nil
 		} else {
-			cf := Int64Flag{}
+			cf := Int64Flag{value: def}
 			m[name] = &cf
-			flag.Var(&cf, name, desc)
+			fs.Var(&cf, name, desc)
 			val := Int64Value{name, isDefVal, def, &cf, t}
 			return &val, nil
 		}
 
-	case uint64Type:
-		var def uint64
-		if isDefVal {
-			if def, err = strconv.ParseUint(defVal, 0, 64); err != nil {
-				return nil, err
-			}
-		}
+	case *uint64:
+		def, _ := d.(uint64)
 		if cf, ok := m[name]; ok {
 			val := Uint64Value{name, isDefVal, def, cf, t}
 			return &val, nil
 		} else {
-			cf := Uint64Flag{}
+			cf := Uint64Flag{value: def}
 			m[name] = &cf
-			flag.Var(&cf, name, desc)
+			fs.Var(&cf, name, desc)
 			val := Uint64Value{name, isDefVal, def, &cf, t}
 			return &val, nil
 		}
 
-	case float64Type:
-		var def float64
-		if isDefVal {
-			if def, err = strconv.ParseFloat(defVal, 64); err != nil {
-				return nil, err
-			}
-		}
+	case *float64:
+		def, _ := d.(float64)
 		if cf, ok := m[name]; ok {
 			val := Float64Value{name, isDefVal, def, cf, t}
 			return &val, nil
 		} else {
-			cf := Float64Flag{}
+			cf := Float64Flag{value: def}
 			m[name] = &cf
-			flag.Var(&cf, name, desc)
+			fs.Var(&cf, name, desc)
 			val := Float64Value{name, isDefVal, def, &cf, t}
 			return &val, nil
 		}
 
-	case boolType:
-		var def bool
-		if isDefVal {
-			if def, err = strconv.ParseBool(defVal); err != nil {
-				return nil, err
-			}
-		}
+	case *bool:
+		def, _ := d.(bool)
 		if cf, ok := m[name]; ok {
 			val := BoolValue{name, isDefVal, def, cf, t}
 			return &val, nil
 		} else {
-			cf := BoolFlag{}
+			cf := BoolFlag{value: def}
 			m[name] = &cf
-			flag.Var(&cf, name, desc)
+			fs.Var(&cf, name, desc)
 			val := BoolValue{name, isDefVal, def, &cf, t}
 			return &val, nil
 		}
 
-	case durationType:
-		var def time.Duration
-		if isDefVal {
-			if def, err = time.ParseDuration(defVal); err != nil {
-				return nil, err
-			}
+	case *ByteSize:
+		return newByteSizeValue(fs, m, t, name, d, desc)
+
+	case *time.Time:
+		layout := vt.layout
+		def, _ := d.(time.Time)
+		if cf, ok := m[name]; ok {
+			val := TimeValue{name, isDefVal, def, cf, t, layout}
+			return &val, nil
+		} else {
+			cf := TimeFlag{value: def, layout: layout}
+			m[name] = &cf
+			fs.Var(&cf, name, desc)
+			val := TimeValue{name, isDefVal, def, &cf, t, layout}
+			return &val, nil
 		}
+
+	case **time.Location:
+		def, _ := d.(*time.Location)
+		if cf, ok := m[name]; ok {
+			val := LocationValue{name, isDefVal, def, cf, t}
+			return &val, nil
+		} else {
+			cf := LocationFlag{value: def}
+			m[name] = &cf
+			fs.Var(&cf, name, desc)
+			val := LocationValue{name, isDefVal, def, &cf, t}
+			return &val, nil
+		}
+
+	case *time.Weekday:
+		def, _ := d.(time.Weekday)
+		if cf, ok := m[name]; ok {
+			val := WeekdayValue{name, isDefVal, def, cf, t}
+			return &val, nil
+		} else {
+			cf := WeekdayFlag{value: def}
+			m[name] = &cf
+			fs.Var(&cf, name, desc)
+			val := WeekdayValue{name, isDefVal, def, &cf, t}
+			return &val, nil
+		}
+
+	case *time.Duration:
+		def, _ := d.(time.Duration)
 		if cf, ok := m[name]; ok {
 			val := DurationValue{name, isDefVal, def, cf, t}
 			return &val, nil
 		} else {
-			cf := DurationFlag{}
+			cf := DurationFlag{value: def}
 			m[name] = &cf
-			flag.Var(&cf, name, desc)
+			fs.Var(&cf, name, desc)
 			val := DurationValue{name, isDefVal, def, &cf, t}
 			return &val, nil
 		}
+
+	case *FeatureFlag:
+		def, _ := d.(FeatureFlag)
+		if isDefVal {
+			def.name = name
+		}
+		if cf, ok := m[name]; ok {
+			val := FeatureFlagValue{name, isDefVal, def, cf, t}
+			return &val, nil
+		} else {
+			cf := FeatureFlagFlag{value: def}
+			m[name] = &cf
+			fs.Var(&cf, name, desc)
+			val := FeatureFlagValue{name, isDefVal, def, &cf, t}
+			return &val, nil
+		}
 	}
 
-	return nil, fmt.Errorf("Unknow Type: %s", t.Type())
+	return nil, &FieldError{Name: name,
+		Err: fmt.Errorf("Unsupported type %s", reflect.TypeOf(p).Elem())}
+}
+
+func newByteSizeValue(fs *flag.FlagSet, m map[string]ConfigFlag,
+	t interface{}, name string, d interface{}, desc string) (SetValue, error) {
+
+	def, isDefVal := d.(ByteSize)
+	if cf, ok := m[name]; ok {
+		val := ByteSizeValue{name, isDefVal, def, cf, t}
+		return &val, nil
+	} else {
+		cf := ByteSizeFlag{value: def}
+		m[name] = &cf
+		fs.Var(&cf, name, desc)
+		val := ByteSizeValue{name, isDefVal, def, &cf, t}
+		return &val, nil
+	}
 }
@@ -0,0 +1,160 @@
+/*
+Package configtest loads configuration structures hermetically for tests. Each
+load uses its own flag set, the given arguments and environment and files
+written to a temporary directory, never the process's command line or
+environment, so tests can run with t.Parallel().
+*/
+package configtest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/heathedavid/config"
+)
+
+// The inputs of a load.
+type Case struct {
+	Args []string          // Command line arguments (without the program)
+	Env  map[string]string // The whole environment
+	// Files by name and content, written to a temporary directory and added
+	// to the chain as FileSources, in name order, after the environment.
+	Files   map[string]string
+	Options []config.Option // Applied after the above
+}
+
+// The outcome of a load.
+type Result struct {
+	Loader     *config.Loader
+	Provenance map[string]string // See Loader.Provenance
+	Help       string            // The usage the flag set prints
+	Warnings   []string          // Messages logged by the Loader
+	Dir        string            // Where the files were written
+}
+
+// Records what the Loader logs.
+type logger struct {
+	messages []string
+}
+
+func (l *logger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+// Load structs for c, failing the test if the load fails.
+func Load(t testing.TB, c Case, structs ...interface{}) *Result {
+	t.Helper()
+
+	r, err := load(t, c, structs)
+	if err != nil {
+		t.Fatalf("configtest: Load failed: %v", err)
+	}
+	return r
+}
+
+// Load structs for c, failing the test unless the load fails. Returns the
+// error so it can be examined further.
+func LoadError(t testing.TB, c Case, structs ...interface{}) error {
+	t.Helper()
+
+	_, err := load(t, c, structs)
+	if err == nil {
+		t.Fatalf("configtest: Load should have failed")
+	}
+	return err
+}
+
+func load(t testing.TB, c Case, structs []interface{}) (*Result, error) {
+	t.Helper()
+
+	r := &Result{Dir: t.TempDir()}
+
+	env := c.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+	sources := []config.Source{config.FlagSource(), config.EnvSource(nil)}
+
+	var names []string
+	for name := range c.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(r.Dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("configtest: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(c.Files[name]), 0600); err != nil {
+			t.Fatalf("configtest: %v", err)
+		}
+		sources = append(sources, config.FileSource(path))
+	}
+	sources = append(sources, config.DefaultSource())
+
+	var help bytes.Buffer
+	fs := flag.NewFlagSet(t.Name(), flag.ContinueOnError)
+	fs.SetOutput(&help)
+
+	log := &logger{}
+	opts := []config.Option{
+		config.WithFlagSet(fs, c.Args),
+		config.WithEnvMap(env),
+		config.WithSources(sources...),
+		config.WithLogger(log),
+	}
+	r.Loader = config.NewLoader(append(opts, c.Options...)...)
+
+	err := r.Loader.Load(structs...)
+
+	// Only the usage should be in the help
+	help.Reset()
+	fs.Usage()
+
+	r.Provenance = r.Loader.Provenance()
+	r.Help = help.String()
+	r.Warnings = log.messages
+	return r, err
+}
+
+// Fail the test unless the help contains each of the strings.
+func (r *Result) AssertHelp(t testing.TB, contains ...string) {
+	t.Helper()
+
+	for _, s := range contains {
+		if !strings.Contains(r.Help, s) {
+			t.Errorf("configtest: help should contain %q:\n%s", s, r.Help)
+		}
+	}
+}
+
+// Fail the test unless field (e.g. "Server.Port") was set by source.
+func (r *Result) AssertSource(t testing.TB, field, source string) {
+	t.Helper()
+
+	if got := r.Provenance[field]; got != source {
+		t.Errorf("configtest: %s should be set by %q not %q", field, source,
+			got)
+	}
+}
+
+// Fail the test unless err's message contains each of the strings.
+func AssertError(t testing.TB, err error, contains ...string) {
+	t.Helper()
+
+	if err == nil {
+		t.Errorf("configtest: expected an error")
+		return
+	}
+	for _, s := range contains {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("configtest: error should contain %q: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package configtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/heathedavid/config"
+)
+
+type server struct {
+	Host  string `env_name:"HOST" env_def:"localhost" env_desc:"Host to bind"`
+	Port  int    `env_name:"PORT" env_def:"8080"`
+	Debug bool   `env_name:"DEBUG" env_alias:"VERBOSE" env_deprecated:"use DEBUG"`
+}
+
+func (s *server) Validate() error {
+	if s.Port <= 0 {
+		return errors.New("PORT must be positive")
+	}
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	var s server
+	r := Load(t, Case{
+		Args:  []string{"-PORT", "90"},
+		Env:   map[string]string{"VERBOSE": "true"},
+		Files: map[string]string{"config.json": `{"HOST": "file"}`},
+	}, &s)
+
+	if s.Host != "file" || s.Port != 90 || !s.Debug {
+		t.Error("Should be loaded from the case", s)
+	}
+
+	r.AssertSource(t, "server.Port", "flag")
+	r.AssertSource(t, "server.Debug", "env")
+	r.AssertHelp(t, "Host to bind (default localhost)")
+
+	if len(r.Warnings) != 1 {
+		t.Error("Should record the deprecation warning", r.Warnings)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Parallel()
+
+	var s server
+	r := Load(t, Case{}, &s)
+
+	if s.Host != "localhost" || s.Port != 8080 || s.Debug {
+		t.Error("Should be the defaults", s)
+	}
+	r.AssertSource(t, "server.Host", "default")
+}
+
+func TestLoadError(t *testing.T) {
+	t.Parallel()
+
+	var s server
+	err := LoadError(t, Case{Env: map[string]string{"PORT": "-1"}}, &s)
+	AssertError(t, err, "PORT must be positive")
+}
+
+func TestLoadOptions(t *testing.T) {
+	t.Parallel()
+
+	var s server
+	r := Load(t, Case{
+		Args:    []string{"--PORT=1", "rest"},
+		Options: []config.Option{config.WithGNUParser()},
+	}, &s)
+
+	if s.Port != 1 || len(r.Loader.Args()) != 1 {
+		t.Error("Options should be applied", s, r.Loader.Args())
+	}
+	r.AssertHelp(t, "--PORT value")
+}
+
+// Each parallel test has its own flags and environment.
+func TestParallel(t *testing.T) {
+	for _, port := range []string{"1", "2", "3", "4"} {
+		port := port
+		t.Run(port, func(t *testing.T) {
+			t.Parallel()
+
+			var s server
+			Load(t, Case{Env: map[string]string{"PORT": port}}, &s)
+			if s.Port != int(port[0]-'0') {
+				t.Error("Port should be", port, "not", s.Port)
+			}
+		})
+	}
+}
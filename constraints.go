@@ -0,0 +1,229 @@
+/*
+Constraints between the fields of a struct are given with tags naming another
+field of the same struct (by Go name, dotted to reach into a nested struct):
+
+	TLSKey   string `env_required_if:"TLS"`          // set if TLS is set
+	Mode     string `env_required_if:"Backend=sql"`  // set if Backend is sql
+	Password string `env_excludes:"PasswordFile"`    // not both set
+	MinConns int    `env_lte:"MaxConns"`             // also env_lt, env_gt, env_gte
+
+A field is set if it is not the zero value. Constraints are checked once all
+the fields are set, before the struct's Validate method, which is not called if
+they fail.
+*/
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A field breaks the constraint given by one of its tags.
+type ConstraintError struct {
+	Struct string // name of the struct type
+	Field  string // name of the struct field, dotted if nested
+	Name   string // flag/env name
+	Tag    string // e.g. env_required_if
+	Other  string // flag/env name of the field the tag refers to
+	Err    error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("Invalid %s: %v", e.Struct, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// A constraint between the fields of a plan, by position.
+type constraint struct {
+	field, other int
+	tag          string
+	value        string // env_required_if value, if any
+}
+
+// Comparison constraint tags and the operator they check.
+var comparisons = map[string]string{
+	"env_lt":  "<",
+	"env_lte": "<=",
+	"env_gt":  ">",
+	"env_gte": ">=",
+}
+
+// Work out the constraints between p's fields from their tags.
+func (p *plan) addConstraints() {
+	byName := make(map[string]int, len(p.fields))
+	for i, f := range p.fields {
+		byName[f.name] = i
+	}
+
+	for i, f := range p.fields {
+		if f.err != nil {
+			continue
+		}
+
+		parent := ""
+		if j := strings.LastIndex(f.name, "."); j >= 0 {
+			parent = f.name[:j+1]
+		}
+		add := func(tag, other, value string) {
+			j, ok := byName[parent+other]
+			if !ok || j == i || p.fields[j].err != nil {
+				p.tagErrs = append(p.tagErrs, &TagError{Field: f.name, Tag: tag,
+					Value: f.tag.Get(tag),
+					Err:   fmt.Errorf("No configuration field %s", other)})
+				return
+			}
+			p.constraints = append(p.constraints,
+				constraint{field: i, other: j, tag: tag, value: value})
+		}
+
+		if v, ok := f.tag.Lookup("env_required_if"); ok {
+			other, value, _ := strings.Cut(v, "=")
+			add("env_required_if", strings.TrimSpace(other), value)
+		}
+		if v, ok := f.tag.Lookup("env_excludes"); ok {
+			for _, other := range strings.Split(v, ",") {
+				add("env_excludes", strings.TrimSpace(other), "")
+			}
+		}
+		for _, tag := range []string{"env_lt", "env_lte", "env_gt", "env_gte"} {
+			if v, ok := f.tag.Lookup(tag); ok {
+				add(tag, strings.TrimSpace(v), "")
+			}
+		}
+	}
+}
+
+// A constraint between two registered fields of a struct.
+type check struct {
+	strct        interface{}
+	tag          string
+	value        string
+	field, other *field
+}
+
+// Register the constraints of plan p for the struct s, whose fields are in
+// fields by position (nil if not registered).
+func (l *Loader) registerConstraints(s interface{}, strct string, p *plan,
+	fields []*field) []error {
+
+	var errs []error
+	for _, e := range p.tagErrs {
+		te := *e
+		te.Struct = strct
+		errs = append(errs, &te)
+	}
+
+	for _, c := range p.constraints {
+		f, other := fields[c.field], fields[c.other]
+		if f == nil || other == nil {
+			continue // already reported
+		}
+
+		if _, ok := comparisons[c.tag]; ok {
+			if f.typeName() != other.typeName() || !ordered(f.value.get()) {
+				errs = append(errs, &TagError{strct, f.name, c.tag,
+					p.fields[c.field].tag.Get(c.tag),
+					fmt.Errorf("Can not compare %s with %s",
+						f.typeName(), other.typeName())})
+				continue
+			}
+		}
+		l.checks = append(l.checks, check{s, c.tag, c.value, f, other})
+	}
+	return errs
+}
+
+// Check the constraints of struct s, returning an error for each broken.
+func (l *Loader) checkConstraints(s interface{}) []error {
+	var errs []error
+	for _, c := range l.checks {
+		if c.strct != s {
+			continue
+		}
+		if err := c.check(); err != nil {
+			errs = append(errs, &ConstraintError{c.field.strct, c.field.name,
+				c.field.key, c.tag, c.other.key, err})
+		}
+	}
+	return errs
+}
+
+func (c check) check() error {
+	f, other := c.field, c.other
+
+	switch c.tag {
+	case "env_required_if":
+		if !isZero(f.value.get()) {
+			return nil
+		}
+		if c.value == "" && !isZero(other.value.get()) {
+			return fmt.Errorf("%s is required when %s is set", f.key, other.key)
+		}
+		if c.value != "" && other.current() == c.value {
+			return fmt.Errorf("%s is required when %s is %s", f.key, other.key,
+				c.value)
+		}
+
+	case "env_excludes":
+		if !isZero(f.value.get()) && !isZero(other.value.get()) {
+			return fmt.Errorf("%s and %s can not both be set", f.key,
+				other.key)
+		}
+
+	default:
+		n, _ := compareValues(f.value.get(), other.value.get())
+		var ok bool
+		switch c.tag {
+		case "env_lt":
+			ok = n < 0
+		case "env_lte":
+			ok = n <= 0
+		case "env_gt":
+			ok = n > 0
+		case "env_gte":
+			ok = n >= 0
+		}
+		if !ok {
+			return fmt.Errorf("%s (%s) must be %s %s (%s)", f.key, f.display(),
+				comparisons[c.tag], other.key, other.display())
+		}
+	}
+	return nil
+}
+
+// Whether x, the value of a field, has a type compareValues can compare.
+func ordered(x interface{}) bool {
+	_, ok := compareValues(x, x)
+	return ok
+}
+
+// -1, 0 or 1 as a is less than, equal to or greater than b, the values of
+// fields of the same type; false if the type is not ordered.
+func compareValues(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string)), true
+	case int:
+		return cmp.Compare(a, b.(int)), true
+	case int64:
+		return cmp.Compare(a, b.(int64)), true
+	case uint64:
+		return cmp.Compare(a, b.(uint64)), true
+	case float64:
+		return cmp.Compare(a, b.(float64)), true
+	case ByteSize:
+		return cmp.Compare(a, b.(ByteSize)), true
+	case time.Duration:
+		return cmp.Compare(a, b.(time.Duration)), true
+	case time.Weekday:
+		return cmp.Compare(a, b.(time.Weekday)), true
+	case time.Time:
+		return a.Compare(b.(time.Time)), true
+	}
+	return 0, false
+}
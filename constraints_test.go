@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type constraintsTLS struct {
+	Enabled bool   `env_name:"enabled"`
+	Key     string `env_name:"key" env_required_if:"Enabled"`
+}
+
+type constraintsTest struct {
+	Backend      string `env_name:"backend" env_def:"file"`
+	DSN          string `env_name:"dsn" env_required_if:"Backend=sql"`
+	Password     string `env_name:"password" env_excludes:"PasswordFile" env_secret:""`
+	PasswordFile string `env_name:"password_file"`
+	MinConns     int    `env_name:"min_conns" env_def:"1" env_lte:"MaxConns"`
+	MaxConns     int    `env_name:"max_conns" env_def:"10"`
+	Start        time.Time
+	End          time.Time      `env_gt:"Start"`
+	TLS          constraintsTLS `env_name:"tls"`
+	validated    bool           `env_no:""`
+}
+
+func (c *constraintsTest) Validate() error {
+	c.validated = true
+	return nil
+}
+
+func TestConstraints(t *testing.T) {
+	var c constraintsTest
+	_, _, err := testLoad(nil, map[string]string{"End": "2017-06-01T00:00:00Z"},
+		nil, &c)
+	if err != nil || !c.validated {
+		t.Fatal("Expected the constraints to hold", err)
+	}
+
+	env := map[string]string{
+		"backend":       "sql",
+		"password":      "s3cr3t",
+		"password_file": "/run/secret",
+		"min_conns":     "20",
+		"tls_enabled":   "true",
+	}
+	c = constraintsTest{}
+	_, _, err = testLoad(nil, env, nil, &c)
+	if err == nil || c.validated {
+		t.Fatal("Expected the constraints to fail before Validate")
+	}
+
+	for _, want := range []string{
+		"dsn is required when backend is sql",
+		"password and password_file can not both be set",
+		"min_conns (20) must be <= max_conns (10)",
+		"End (0001-01-01T00:00:00Z) must be > Start (0001-01-01T00:00:00Z)",
+		"tls_key is required when tls_enabled is set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Error("Expected", want, "in", err)
+		}
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Error("Secret should not be shown", err)
+	}
+
+	var ce *ConstraintError
+	if !errors.As(err, &ce) || ce.Struct != "constraintsTest" {
+		t.Error("Expected a ConstraintError", err)
+	}
+}
+
+func TestConstraintTagErrors(t *testing.T) {
+	tests := []struct {
+		strct interface{}
+		value string
+		err   string
+	}{
+		{&struct {
+			A int `env_lt:"B"`
+		}{}, "B", "No configuration field B"},
+		{&struct {
+			A int    `env_lt:"B"`
+			B string `env_name:"b"`
+		}{}, "B", "Can not compare int with string"},
+		{&struct {
+			A bool `env_gt:"B"`
+			B bool
+		}{}, "B", "Can not compare bool with bool"},
+	}
+
+	for _, test := range tests {
+		var te *TagError
+		_, _, err := testLoad(nil, nil, nil, test.strct)
+		if !errors.As(err, &te) || !strings.Contains(err.Error(), test.err) {
+			t.Error("Expected", test.err, err)
+			continue
+		}
+		if te.Value != test.value {
+			t.Error("Expected the tag's value", test.value, "not", te.Value)
+		}
+	}
+}
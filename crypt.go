@@ -0,0 +1,210 @@
+/*
+Values can be committed encrypted, as enc:v1:<base64> strings made by
+cmd/configcrypt, in files, the environment or env_def. A Loader given keys
+(WithKeys, WithKeyFile or WithKeyEnv) decrypts them with AES-GCM before parsing
+them. Fields which have been set from an encrypted value are treated as
+env_secret from then on, by the Loader and by Log.
+*/
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The prefix of encrypted values.
+const encPrefix = "enc:v1:"
+
+// Whether s is an encrypted value.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, encPrefix)
+}
+
+// A new random 256 bit key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Parse keys encoded in base64 separated by newlines or commas, as read by
+// WithKeyFile and WithKeyEnv. The first key is the current one, the others are
+// old keys still accepted when decrypting.
+func ParseKeys(s string) ([][]byte, error) {
+	var keys [][]byte
+	for _, k := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	}) {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key: %v", err)
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("Invalid key: %d bytes, not 16, 24 or 32",
+				len(key))
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No keys")
+	}
+	return keys, nil
+}
+
+// Encrypt plaintext with key, giving an enc:v1: value.
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(encPrefix))
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt the enc:v1: value with the first of keys which can.
+func Decrypt(keys [][]byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", fmt.Errorf("Not an encrypted value")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(encPrefix):])
+	if err != nil {
+		return "", fmt.Errorf("Invalid encrypted value: %v", err)
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("No key to decrypt the value with, " +
+			"see WithKeyFile")
+	}
+
+	for _, key := range keys {
+		gcm, err := newGCM(key)
+		if err != nil {
+			return "", err
+		}
+		if len(sealed) < gcm.NonceSize() {
+			return "", fmt.Errorf("Invalid encrypted value: too short")
+		}
+		nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+		plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(encPrefix))
+		if err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", fmt.Errorf("None of the keys decrypt the value")
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Decrypt values with keys, the first is the current key.
+func WithKeys(keys ...[]byte) Option {
+	return func(l *Loader) {
+		l.keys = append(l.keys, keys...)
+	}
+}
+
+// Decrypt values with the keys in the file at path (see ParseKeys), read by
+// Load.
+func WithKeyFile(path string) Option {
+	return func(l *Loader) {
+		l.keyFile = path
+	}
+}
+
+// Decrypt values with the keys in the environment variable name (see
+// ParseKeys), read by Load.
+func WithKeyEnv(name string) Option {
+	return func(l *Loader) {
+		l.keyEnv = name
+	}
+}
+
+// Read the keys from the key file and environment variable, if given, once.
+func (l *Loader) readKeys() error {
+	if l.keysRead {
+		return nil
+	}
+
+	if l.keyFile != "" {
+		b, err := os.ReadFile(l.keyFile)
+		if err != nil {
+			return err
+		}
+		keys, err := ParseKeys(string(b))
+		if err != nil {
+			return fmt.Errorf("%s: %v", l.keyFile, err)
+		}
+		l.keys = append(l.keys, keys...)
+	}
+
+	if l.keyEnv != "" {
+		s, ok := l.lookupEnv(l.keyEnv)
+		if !ok {
+			return fmt.Errorf("Key variable %s is not set", l.keyEnv)
+		}
+		keys, err := ParseKeys(s)
+		if err != nil {
+			return fmt.Errorf("%s: %v", l.keyEnv, err)
+		}
+		l.keys = append(l.keys, keys...)
+	}
+	l.keysRead = true
+	return nil
+}
+
+// The names of the fields set from encrypted values by the pointer to their
+// struct, so Log can redact them.
+var decrypted = struct {
+	sync.Mutex
+	m map[interface{}]map[string]bool
+}{m: make(map[interface{}]map[string]bool)}
+
+// Treat f as a secret from now on.
+func (f *field) markSecret() {
+	f.secret = true
+	if f.parent == nil {
+		return
+	}
+
+	decrypted.Lock()
+	defer decrypted.Unlock()
+	if decrypted.m[f.parent] == nil {
+		decrypted.m[f.parent] = make(map[string]bool)
+	}
+	decrypted.m[f.parent][f.name] = true
+}
+
+// The names of the fields of the struct strct points to which have been set
+// from encrypted values.
+func decryptedFields(strct interface{}) map[string]bool {
+	decrypted.Lock()
+	defer decrypted.Unlock()
+	names := make(map[string]bool, len(decrypted.m[strct]))
+	for name := range decrypted.m[strct] {
+		names[name] = true
+	}
+	return names
+}
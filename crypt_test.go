@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testEncrypt(t *testing.T, key []byte, plaintext string) string {
+	value, err := Encrypt(key, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, old := testKey(t), testKey(t)
+
+	value := testEncrypt(t, key, "hunter2")
+	if !IsEncrypted(value) || strings.Contains(value, "hunter2") {
+		t.Error("Unexpected encrypted value", value)
+	}
+	if v, err := Decrypt([][]byte{key}, value); err != nil || v != "hunter2" {
+		t.Error("Should decrypt to hunter2", v, err)
+	}
+	if v, err := Decrypt([][]byte{old, key}, value); err != nil || v != "hunter2" {
+		t.Error("Any of the keys should decrypt", v, err)
+	}
+	if _, err := Decrypt([][]byte{old}, value); err == nil {
+		t.Error("The wrong key should fail")
+	}
+	if _, err := Decrypt(nil, value); err == nil ||
+		!strings.Contains(err.Error(), "No key") {
+		t.Error("No keys should fail", err)
+	}
+	if _, err := Decrypt([][]byte{key}, value[:len(value)-4]+"AAA="); err == nil {
+		t.Error("A changed value should fail")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	key, old := testKey(t), testKey(t)
+	s := base64.StdEncoding.EncodeToString(key) + "\n" +
+		base64.StdEncoding.EncodeToString(old) + ",\n"
+
+	keys, err := ParseKeys(s)
+	if err != nil || len(keys) != 2 || !bytes.Equal(keys[0], key) ||
+		!bytes.Equal(keys[1], old) {
+		t.Error("Unexpected keys", keys, err)
+	}
+
+	for _, s := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := ParseKeys(s); err == nil {
+			t.Errorf("%q should fail", s)
+		}
+	}
+}
+
+type cryptDB struct {
+	Password string `env_name:"password"`
+}
+
+type cryptTest struct {
+	Token string `env_name:"token"`
+	DB    cryptDB
+	Salt  string `env_name:"salt"`
+	Port  int    `env_name:"port" env_def:"80"`
+}
+
+func TestLoadEncrypted(t *testing.T) {
+	key := testKey(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	doc, _ := json.Marshal(map[string]string{
+		"DB_password": testEncrypt(t, key, "hunter2")})
+	if err := os.WriteFile(path, doc, 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "config.key")
+	if err := os.WriteFile(keyFile,
+		[]byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg cryptTest
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithKeyFile(keyFile),
+		WithEnvMap(map[string]string{
+			"token": testEncrypt(t, key, "s3cret"), "salt": "pepper"}),
+		WithSource(FileSource(path), -1), WithExpvar("config_crypt_test"))
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "s3cret" || cfg.DB.Password != "hunter2" ||
+		cfg.Salt != "pepper" || cfg.Port != 80 {
+		t.Error("Unexpected config", cfg)
+	}
+
+	w := adminRequest(NewAdminHandler(l), "GET", "/", "")
+	if strings.Contains(w.Body.String(), "s3cret") ||
+		strings.Contains(w.Body.String(), "hunter2") ||
+		!strings.Contains(w.Body.String(), "pepper") {
+		t.Error("Decrypted values should be redacted", w.Body)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", l.Log(&cfg))
+	if strings.Contains(buf.String(), "s3cret") ||
+		strings.Contains(buf.String(), "hunter2") ||
+		!strings.Contains(buf.String(), "pepper") {
+		t.Error("Decrypted values should be redacted", buf.String())
+	}
+
+	buf.Reset()
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", Log(&cfg))
+	if strings.Contains(buf.String(), "s3cret") ||
+		strings.Contains(buf.String(), "hunter2") ||
+		!strings.Contains(buf.String(), "pepper") {
+		t.Error("Log should redact decrypted values too", buf.String())
+	}
+
+	buf.Reset()
+	other := cryptTest{Token: "plain"}
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", l.Log(&other),
+		Log(&other))
+	if strings.Count(buf.String(), "plain") != 2 {
+		t.Error("Only the fields the Loader decrypted should be redacted",
+			buf.String())
+	}
+
+	published := expvar.Get("config_crypt_test").String()
+	if strings.Contains(published, "s3cret") ||
+		strings.Contains(published, "hunter2") {
+		t.Error("Decrypted values should be redacted", published)
+	}
+}
+
+// Encrypted with the key AAECAwQFBgcICQoLDA0ODw==
+type cryptDefault struct {
+	Password string `env_name:"password" env_def:"enc:v1:YCxDIO2yPTV7pqun70DJTGYGW2S8hOjKcjWXS5fgKWFMAk0="`
+}
+
+func TestLoadEncryptedDefault(t *testing.T) {
+	keys, err := ParseKeys("AAECAwQFBgcICQoLDA0ODw==")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg cryptDefault
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithEnvMap(nil), WithKeys(keys...))
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "hunter2" {
+		t.Error("Unexpected password", cfg.Password)
+	}
+	if f := fs.Lookup("password"); f == nil || f.DefValue != "" {
+		t.Error("The help should not show the encrypted default", f)
+	}
+}
+
+type cryptPort struct {
+	Port  int    `env_name:"port"`
+	Token string `env_name:"token"`
+}
+
+func TestLoadEncryptedErrors(t *testing.T) {
+	key := testKey(t)
+	env := map[string]string{"token": testEncrypt(t, key, "s3cret")}
+
+	_, _, err := testLoad(nil, env, nil, &cryptPort{})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Name != "token" ||
+		!strings.Contains(err.Error(), "No key") {
+		t.Error("Expected a missing key error", err)
+	}
+
+	env = map[string]string{"port": testEncrypt(t, key, "eighty")}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	err = NewLoader(WithFlagSet(fs, nil), WithEnvMap(env),
+		WithKeys(key)).Load(&cryptPort{})
+	if err == nil || strings.Contains(err.Error(), "eighty") {
+		t.Error("The decrypted value should be redacted", err)
+	}
+}
+
+func TestKeyEnv(t *testing.T) {
+	key := testKey(t)
+	env := map[string]string{
+		"CONFIG_KEY": base64.StdEncoding.EncodeToString(key),
+		"token":      testEncrypt(t, key, "s3cret"),
+	}
+
+	var cfg cryptPort
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := NewLoader(WithFlagSet(fs, nil), WithEnvMap(env),
+		WithKeyEnv("CONFIG_KEY")).Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "s3cret" {
+		t.Error("Unexpected token", cfg.Token)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	err := NewLoader(WithFlagSet(fs, nil), WithEnvMap(nil),
+		WithKeyEnv("CONFIG_KEY")).Load(&cryptPort{})
+	if err == nil || !strings.Contains(err.Error(), "CONFIG_KEY") {
+		t.Error("A missing key variable should fail", err)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Defaults which are not constants are computed in two ways. A struct passed to
+Load which implements Defaults has the method called before its flags are
+registered, the fields it sets (leaves non-zero) take their values as their
+defaults in place of env_def. A field tagged env_def_func:"name" takes its
+default from the function registered with that name. The function is called
+once the other fields hold their defaults, which the help shows it computed
+from, and again each time the field is set so it can use the values of the
+fields declared before it.
+*/
+package config
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// If the struct being configured implements this interface, it will be called
+// before the flags are registered to set computed defaults.
+type Defaults interface {
+	// Set fields to their defaults, e.g. Workers to runtime.NumCPU().
+	Defaults()
+}
+
+// Computes the default of fields tagged env_def_func. strct is the pointer
+// passed to Load, for the help its other fields hold their defaults and when
+// it is loaded the fields declared before the field are already set.
+type DefaultFunc func(strct interface{}) (string, error)
+
+var defaultFuncs = struct {
+	sync.RWMutex
+	m map[string]DefaultFunc
+}{m: map[string]DefaultFunc{
+	"numcpu": func(interface{}) (string, error) {
+		return strconv.Itoa(runtime.NumCPU()), nil
+	},
+	"hostname": func(interface{}) (string, error) {
+		return os.Hostname()
+	},
+}}
+
+// Register fn as the default function name for env_def_func tags, replacing
+// any function already registered with the name. numcpu and hostname are
+// registered already.
+func RegisterDefault(name string, fn DefaultFunc) {
+	defaultFuncs.Lock()
+	defer defaultFuncs.Unlock()
+	defaultFuncs.m[name] = fn
+}
+
+func lookupDefaultFunc(name string) (DefaultFunc, bool) {
+	defaultFuncs.RLock()
+	defer defaultFuncs.RUnlock()
+	fn, ok := defaultFuncs.m[name]
+	return fn, ok
+}
+
+func isDefaults(strct interface{}) bool {
+	_, ok := strct.(Defaults)
+	return ok
+}
+
+// Call the Defaults method of strct, if it has one.
+func callDefaults(strct interface{}) {
+	if d, ok := strct.(Defaults); ok {
+		d.Defaults()
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type defaultsTest struct {
+	Workers int           `env_name:"workers" env_def:"1"`
+	Timeout time.Duration `env_name:"timeout"`
+	Name    string        `env_name:"name"`
+}
+
+func (d *defaultsTest) Defaults() {
+	d.Workers = 3
+	d.Timeout = 36 * time.Hour
+}
+
+func TestDefaultsMethod(t *testing.T) {
+	var d defaultsTest
+	l, out, err := testLoad(nil, nil, nil, &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.PrintDefaults()
+	help := out.String()
+	if d.Workers != 3 || d.Timeout != 36*time.Hour {
+		t.Error("Defaults should replace env_def", d)
+	}
+	if p := l.Provenance(); p["defaultsTest.Workers"] != "Defaults" ||
+		p["defaultsTest.Timeout"] != "Defaults" {
+		t.Error("Unexpected provenance", p)
+	}
+	if _, ok := l.Provenance()["defaultsTest.Name"]; ok {
+		t.Error("Name has no default")
+	}
+	if !strings.Contains(help, "(default 3)") ||
+		!strings.Contains(help, "(default 1d12h)") {
+		t.Error("Help should show the computed defaults", help)
+	}
+
+	d = defaultsTest{}
+	if _, _, err := testLoad(nil, map[string]string{"workers": "8"}, nil,
+		&d); err != nil || d.Workers != 8 {
+		t.Error("The environment should override Defaults", d.Workers, err)
+	}
+}
+
+type defaultFuncTest struct {
+	Port    int `env_name:"port" env_def:"80"`
+	Admin   int `env_name:"admin" env_def_func:"test_double_port"`
+	Workers int `env_name:"workers" env_def_func:"numcpu"`
+}
+
+func TestDefaultFunc(t *testing.T) {
+	RegisterDefault("test_double_port", func(s interface{}) (string, error) {
+		return strconv.Itoa(2 * s.(*defaultFuncTest).Port), nil
+	})
+
+	var d defaultFuncTest
+	l, out, err := testLoad(nil, map[string]string{"port": "8080"}, nil, &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.PrintDefaults()
+	help := out.String()
+	if d.Admin != 16160 || d.Workers != runtime.NumCPU() {
+		t.Error("Unexpected computed defaults", d)
+	}
+	if p := l.Provenance(); p["defaultFuncTest.Admin"] !=
+		"default test_double_port()" ||
+		p["defaultFuncTest.Workers"] != "default numcpu()" {
+		t.Error("Unexpected provenance", p)
+	}
+	if !strings.Contains(help, "(default "+strconv.Itoa(runtime.NumCPU())) {
+		t.Error("Help should show the computed default", help)
+	}
+	// Computed from port's default, which is what the help shows
+	if !strings.Contains(help, "admin (default 160)") {
+		t.Error("Help should show the default computed from port's", help)
+	}
+
+	d = defaultFuncTest{}
+	if _, _, err := testLoad([]string{"-admin", "9"}, nil, nil,
+		&d); err != nil || d.Admin != 9 {
+		t.Error("The flag should override the function", d.Admin, err)
+	}
+}
+
+func TestDefaultFuncErrors(t *testing.T) {
+	RegisterDefault("test_fail", func(interface{}) (string, error) {
+		return "", errors.New("no luck")
+	})
+
+	tests := []struct {
+		strct interface{}
+		err   string
+	}{
+		{&struct {
+			A int `env_def_func:"test_missing"`
+		}{}, "No default function registered"},
+		{&struct {
+			A int `env_def:"1" env_def_func:"numcpu"`
+		}{}, "env_def is given too"},
+		{&struct {
+			A int `env_def_func:"test_fail"`
+		}{}, "from default test_fail(): no luck"},
+	}
+
+	for _, test := range tests {
+		_, _, err := testLoad(nil, nil, nil, test.strct)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Error("Expected", test.err, err)
+		}
+	}
+}
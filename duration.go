@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Day  = 24 * time.Hour
+	Week = 7 * Day
+)
+
+// Matches an ISO-8601 duration without its sign, years and months are not a
+// fixed length so are not supported.
+var isoDuration = regexp.MustCompile(`^P(?:([0-9.,]+)W)?(?:([0-9.,]+)D)?` +
+	`(?:T(?:([0-9.,]+)H)?(?:([0-9.,]+)M)?(?:([0-9.,]+)S)?)?$`)
+
+// Parse a duration as time.ParseDuration does but also accepting days (d) and
+// weeks (w), e.g. 7d or 1w2d12h, and ISO-8601 durations such as P1DT2H.
+func ParseDuration(s string) (time.Duration, error) {
+	t := strings.TrimSpace(s)
+
+	neg := false
+	if t != "" && (t[0] == '-' || t[0] == '+') {
+		neg = t[0] == '-'
+		t = t[1:]
+	}
+
+	var n *big.Rat
+	var err error
+	if strings.HasPrefix(t, "P") {
+		n, err = parseISODuration(t)
+	} else {
+		n, err = parseUnitDuration(t)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration %q: %v", s, err)
+	}
+
+	if neg {
+		n.Neg(n)
+	}
+	i := new(big.Int).Quo(n.Num(), n.Denom())
+	if !i.IsInt64() {
+		return 0, fmt.Errorf("Invalid duration %q: overflow", s)
+	}
+	return time.Duration(i.Int64()), nil
+}
+
+// Nanoseconds in each of the units of a duration such as 1w2d3h.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"μs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  Day,
+	"w":  Week,
+}
+
+func parseUnitDuration(s string) (*big.Rat, error) {
+	if s == "0" {
+		return new(big.Rat), nil
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty")
+	}
+
+	total := new(big.Rat)
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if i <= 0 {
+			return nil, fmt.Errorf("missing number")
+		}
+		num := s[:i]
+		s = s[i:]
+
+		j := strings.IndexAny(s, "0123456789.")
+		if j < 0 {
+			j = len(s)
+		}
+		unit, ok := durationUnits[s[:j]]
+		if !ok {
+			return nil, fmt.Errorf("unknown unit %q", s[:j])
+		}
+		s = s[j:]
+
+		if err := addDuration(total, num, unit); err != nil {
+			return nil, err
+		}
+	}
+	return total, nil
+}
+
+func parseISODuration(s string) (*big.Rat, error) {
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return nil, fmt.Errorf("not an ISO-8601 duration of weeks, " +
+			"days, hours, minutes and seconds")
+	}
+
+	total := new(big.Rat)
+	units := []time.Duration{Week, Day, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		if err := addDuration(total, strings.Replace(m[i+1], ",", ".", 1),
+			unit); err != nil {
+			return nil, err
+		}
+	}
+	return total, nil
+}
+
+// Add num (possibly fractional) of unit to total.
+func addDuration(total *big.Rat, num string, unit time.Duration) error {
+	n, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return fmt.Errorf("invalid number %q", num)
+	}
+	total.Add(total, n.Mul(n, new(big.Rat).SetInt64(int64(unit))))
+	return nil
+}
+
+// Format d with weeks and days, e.g. 1w2d3h4m5.5s, leaving out zero units so
+// 36h is 1d12h. Durations under a second are formatted as time.Duration does.
+// The result parses back to d with ParseDuration.
+func FormatDuration(d time.Duration) string {
+	if d > -time.Second && d < time.Second {
+		return d.String()
+	}
+
+	var b strings.Builder
+	u := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		u = -u
+	}
+
+	for _, unit := range []struct {
+		n    time.Duration
+		name string
+	}{{Week, "w"}, {Day, "d"}, {time.Hour, "h"}, {time.Minute, "m"}} {
+		if q := u / uint64(unit.n); q > 0 {
+			b.WriteString(strconv.FormatUint(q, 10) + unit.name)
+			u -= q * uint64(unit.n)
+		}
+	}
+
+	if u > 0 {
+		secs := strconv.FormatUint(u/uint64(time.Second), 10)
+		if frac := u % uint64(time.Second); frac > 0 {
+			secs += strings.TrimRight(fmt.Sprintf(".%09d", frac), "0")
+		}
+		b.WriteString(secs + "s")
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s string
+		d time.Duration
+	}{
+		{"0", 0},
+		{"10s", 10 * time.Second},
+		{"1.5h", 90 * time.Minute},
+		{"7d", Week},
+		{"1w2d12h", Week + 2*Day + 12*time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"-2d", -2 * Day},
+		{"300ms", 300 * time.Millisecond},
+		{"P1DT2H", Day + 2*time.Hour},
+		{"PT30M", 30 * time.Minute},
+		{"P2W", 2 * Week},
+		{"PT1.5S", 1500 * time.Millisecond},
+		{"PT0,5S", 500 * time.Millisecond},
+		{"-P1D", -Day},
+	}
+
+	for _, test := range tests {
+		if d, err := ParseDuration(test.s); err != nil || d != test.d {
+			t.Error(test.s, "should be", test.d, "not", d, err)
+		}
+	}
+
+	for _, s := range []string{"", "d", "7", "7x", "1.2.3s", "P", "PT", "P1Y",
+		"P1M", "P1H", "100000w"} {
+		if _, err := ParseDuration(s); err == nil {
+			t.Error(s, "should not parse")
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d time.Duration
+		s string
+	}{
+		{0, "0s"},
+		{1500 * time.Microsecond, "1.5ms"},
+		{10 * time.Second, "10s"},
+		{36 * time.Hour, "1d12h"},
+		{Week + 2*Day + 3*time.Hour + 4*time.Minute + 5500*time.Millisecond,
+			"1w2d3h4m5.5s"},
+		{-90 * time.Minute, "-1h30m"},
+	}
+
+	for _, test := range tests {
+		if s := FormatDuration(test.d); s != test.s {
+			t.Error(int64(test.d), "should be", test.s, "not", s)
+		}
+		if d, err := ParseDuration(test.s); err != nil || d != test.d {
+			t.Error(test.s, "should parse back to", test.d)
+		}
+	}
+}
+
+func TestDurationLoad(t *testing.T) {
+	ss := struct {
+		Retention time.Duration `env_def:"30d"`
+		TTL       time.Duration
+		Timeout   time.Duration
+	}{}
+
+	env := map[string]string{"TTL": "P1DT12H"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-Timeout", "1w"}),
+		WithEnv(mapLookup(env)))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.Retention != 30*Day || ss.TTL != 36*time.Hour || ss.Timeout != Week {
+		t.Error("Extended durations should be parsed", ss)
+	}
+
+	if d := fs.Lookup("Retention").DefValue; d != "4w2d" {
+		t.Error("Help should show the canonical default", d)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A field could not be registered or set. Value is redacted for env_secret
+// fields.
+type FieldError struct {
+	Struct string // name of the struct type
+	Field  string // name of the struct field, dotted if nested
+	Name   string // flag/env name
+	Source string // name of the source the value came from, if any
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	s := e.Name
+	if e.Struct != "" && e.Field != "" {
+		s += " (" + e.Struct + "." + e.Field + ")"
+	} else if e.Field != "" {
+		s += " (" + e.Field + ")"
+	}
+	if e.Source != "" {
+		s += " from " + e.Source
+	}
+	if e.Value != "" {
+		return fmt.Sprintf("Invalid value %q for %s: %v", e.Value, s, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", s, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// A struct tag can not be used on a field.
+type TagError struct {
+	Struct string // name of the struct type
+	Field  string // name of the struct field, dotted if nested
+	Tag    string // e.g. env_short
+	Value  string
+	Err    error
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("Invalid tag %s:%q on %s.%s: %v", e.Tag, e.Value,
+		e.Struct, e.Field, e.Err)
+}
+
+func (e *TagError) Unwrap() error {
+	return e.Err
+}
+
+// The Validate method of a struct failed.
+type ValidationError struct {
+	Struct string // name of the struct type
+	Err    error  // returned by Validate
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("Invalid %s: %v", e.Struct, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// nil if there are no errs, the error if there is one, else all of them
+// joined.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// Fill in the names of the field in err from registering it. Errors which are
+// not already a FieldError or TagError are from parsing value from source.
+func registerError(err error, strct, name, key, source, value string) error {
+	switch e := err.(type) {
+	case *TagError:
+		e.Struct, e.Field = strct, name
+		return e
+	case *FieldError:
+		e.Struct, e.Field, e.Name = strct, name, key
+		return e
+	}
+	return &FieldError{strct, name, key, source, value, err}
+}
+
+// An error setting the field from value, which is redacted if the field is a
+// secret, given for key by source.
+func (f *field) fieldError(source, key, value string, err error) *FieldError {
+	if f.secret && value != "" {
+		err = &redactedError{err, value}
+		value = redacted
+	}
+	return &FieldError{f.strct, f.name, key, source, value, err}
+}
+
+// Hides a secret value in the message of the error it wraps. The wrapped
+// error is not exposed as it may hold the value (a *strconv.NumError's Num),
+// only the cause of a number's parse error is.
+type redactedError struct {
+	err    error
+	secret string
+}
+
+func (e *redactedError) Error() string {
+	return strings.Replace(e.err.Error(), e.secret, redacted, -1)
+}
+
+func (e *redactedError) Unwrap() error {
+	var ne *strconv.NumError
+	if errors.As(e.err, &ne) {
+		return ne.Err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type badDefault struct {
+	Port int    `env_name:"port" env_def:"eighty"`
+	Rate int    `env_name:"rate"`
+	Key  string `env_name:"key"`
+}
+
+func TestFieldErrorDefault(t *testing.T) {
+	_, _, err := testLoad(nil, nil, nil, &badDefault{})
+
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatal("Expected a FieldError", err)
+	}
+	if fe.Struct != "badDefault" || fe.Field != "Port" || fe.Name != "port" ||
+		fe.Source != "default" || fe.Value != "eighty" {
+		t.Error("Unexpected FieldError", fe)
+	}
+	if !strings.Contains(err.Error(), `"eighty" for port (badDefault.Port)`) {
+		t.Error("Unexpected message", err)
+	}
+}
+
+type secretValue struct {
+	Token int `env_name:"token" env_secret:""`
+}
+
+func TestFieldErrorJoined(t *testing.T) {
+	_, _, err := testLoad(nil,
+		map[string]string{"rate": "fast", "token": "s3cr3t"}, nil, &struct {
+			Rate int `env_name:"rate"`
+		}{}, &secretValue{})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Fatal("Expected both fields to be reported", err)
+	}
+	for _, e := range joined.Unwrap() {
+		var fe *FieldError
+		if !errors.As(e, &fe) || fe.Source != "env" {
+			t.Error("Unexpected error", e)
+		}
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Error("Secret value should be redacted", err)
+	}
+
+	_, _, err = testLoad(nil, map[string]string{"token": "s3cr3t"}, nil,
+		&secretValue{})
+	var ne *strconv.NumError
+	if errors.As(err, &ne) || !errors.Is(err, strconv.ErrSyntax) {
+		t.Error("Only the cause of the parse error should be exposed", err)
+	}
+}
+
+func TestFieldErrorFlag(t *testing.T) {
+	_, out, err := testLoad([]string{"-port", "abc"}, nil, nil, &struct {
+		Port int `env_name:"port"`
+	}{})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Name != "port" || fe.Source != "flag" ||
+		fe.Value != "abc" {
+		t.Error("Expected a FieldError from the flag", err)
+	}
+
+	_, out, err = testLoad([]string{"-token", "s3cr3t"}, nil, nil,
+		&secretValue{})
+	if !errors.As(err, &fe) || fe.Field != "Token" || fe.Value != redacted {
+		t.Error("Expected a redacted FieldError", err)
+	}
+	if strings.Contains(err.Error(), "s3cr3t") ||
+		strings.Contains(out.String(), "s3cr3t") {
+		t.Error("Secret value should be redacted", err, out)
+	}
+}
+
+type unexportedField struct {
+	Port int `env_name:"port" env_def:"80"`
+	host string
+}
+
+func TestLoadNoPanic(t *testing.T) {
+	if _, _, err := testLoad(nil, nil, nil, unexportedField{}); err == nil {
+		t.Error("Expected an error for a non-pointer")
+	}
+
+	_, _, err := testLoad(nil, nil, nil, &unexportedField{})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "host" {
+		t.Error("Expected a FieldError for the unexported field", err)
+	}
+}
+
+func TestTagError(t *testing.T) {
+	tests := []struct {
+		strct interface{}
+		tag   string
+	}{
+		{&struct {
+			Name string `env_unit:"bytes"`
+		}{}, "env_unit"},
+		{&struct {
+			Port int `env_short:"pp"`
+		}{}, "env_short"},
+	}
+
+	for _, test := range tests {
+		var te *TagError
+		_, _, err := testLoad(nil, nil, nil, test.strct)
+		if !errors.As(err, &te) || te.Tag != test.tag {
+			t.Error("Expected a TagError for", test.tag, err)
+		}
+	}
+}
+
+var errTooLow = errors.New("too low")
+
+type invalidStruct struct {
+	Port int `env_name:"port" env_def:"1"`
+}
+
+func (s *invalidStruct) Validate() error {
+	if s.Port < 1024 {
+		return errTooLow
+	}
+	return nil
+}
+
+func TestValidationError(t *testing.T) {
+	_, _, err := testLoad(nil, nil, nil, &invalidStruct{})
+
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Struct != "invalidStruct" {
+		t.Fatal("Expected a ValidationError", err)
+	}
+	if !errors.Is(err, errTooLow) {
+		t.Error("Expected the Validate error to be wrapped", err)
+	}
+}
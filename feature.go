@@ -0,0 +1,167 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A feature flag, a field which is on or off for each subject (a user or
+// account ID...) checked with Enabled. It is parsed from terms separated by
+// semicolons:
+//
+//	on, off, true, false...  on or off for everyone
+//	25%                      on for a stable 25% of subjects
+//	allow=alice,bob          always on for these subjects
+//	deny=mallory             always off for these subjects
+//
+// e.g. "10%;allow=alice;deny=mallory". As with other fields it is set from
+// the flag, environment, files... and changes when the Loader reloads.
+type FeatureFlag struct {
+	name      string // salts the hash so flags roll out to different subjects
+	permyriad int    // hundredths of a percent of subjects it is on for
+	allow     []string
+	deny      []string
+}
+
+// Parse a feature flag, see FeatureFlag. "" is off.
+func ParseFeatureFlag(s string) (FeatureFlag, error) {
+	var f FeatureFlag
+	rollout := false
+	for _, term := range strings.Split(s, ";") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		if key, list, ok := strings.Cut(term, "="); ok {
+			switch strings.TrimSpace(key) {
+			case "allow":
+				f.allow = splitSubjects(list)
+			case "deny":
+				f.deny = splitSubjects(list)
+			default:
+				return FeatureFlag{}, fmt.Errorf("Unknown feature flag term %q",
+					term)
+			}
+			continue
+		}
+
+		if rollout {
+			return FeatureFlag{}, fmt.Errorf("More than one of on, off or a "+
+				"percentage in %q", s)
+		}
+		rollout = true
+
+		if p, ok := strings.CutSuffix(term, "%"); ok {
+			percent, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil || percent < 0 || percent > 100 {
+				return FeatureFlag{}, fmt.Errorf("Invalid percentage %q", term)
+			}
+			f.permyriad = int(math.Round(percent * 100))
+			continue
+		}
+
+		on, err := strconv.ParseBool(term)
+		switch {
+		case term == "on":
+			on = true
+		case term == "off":
+			on = false
+		case err != nil:
+			return FeatureFlag{}, fmt.Errorf("Invalid feature flag term %q",
+				term)
+		}
+		if on {
+			f.permyriad = 10000
+		}
+	}
+	return f, nil
+}
+
+func splitSubjects(s string) []string {
+	var subjects []string
+	for _, subject := range strings.Split(s, ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// The flag in the form ParseFeatureFlag parses.
+func (f FeatureFlag) String() string {
+	var terms []string
+	switch f.permyriad {
+	case 0:
+		terms = append(terms, "off")
+	case 10000:
+		terms = append(terms, "on")
+	default:
+		terms = append(terms, strconv.FormatFloat(float64(f.permyriad)/100,
+			'f', -1, 64)+"%")
+	}
+	if len(f.allow) > 0 {
+		terms = append(terms, "allow="+strings.Join(f.allow, ","))
+	}
+	if len(f.deny) > 0 {
+		terms = append(terms, "deny="+strings.Join(f.deny, ","))
+	}
+	return strings.Join(terms, ";")
+}
+
+func (f FeatureFlag) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *FeatureFlag) UnmarshalText(text []byte) error {
+	v, err := ParseFeatureFlag(string(text))
+	if err != nil {
+		return err
+	}
+	v.name = f.name
+	*f = v
+	return nil
+}
+
+// Whether the feature is on for subject, unless ctx forces it (see
+// ForceFeature). Denied subjects come before allowed ones, the rest are on if
+// the hash of the flag's name and subject falls in its percentage, so a
+// subject keeps the feature as the percentage grows.
+func (f FeatureFlag) Enabled(ctx context.Context, subject string) bool {
+	if ctx != nil {
+		if on, ok := ctx.Value(featureKey(f.name)).(bool); ok {
+			return on
+		}
+	}
+
+	switch {
+	case slices.Contains(f.deny, subject):
+		return false
+	case slices.Contains(f.allow, subject):
+		return true
+	case f.permyriad >= 10000:
+		return true
+	case f.permyriad <= 0:
+		return false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(f.name))
+	h.Write([]byte{0})
+	h.Write([]byte(subject))
+	return h.Sum64()%10000 < uint64(f.permyriad)
+}
+
+type featureKey string
+
+// A context in which the feature flag with the flag/env name name is on or off
+// for every subject, whatever its rule, e.g. for a test or a request forcing a
+// feature.
+func ForceFeature(ctx context.Context, name string, on bool) context.Context {
+	return context.WithValue(ctx, featureKey(name), on)
+}
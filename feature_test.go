@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseFeatureFlag(t *testing.T) {
+	for s, want := range map[string]string{
+		"":                          "off",
+		"off":                       "off",
+		"true":                      "on",
+		"on":                        "on",
+		"100%":                      "on",
+		"12.5%":                     "12.5%",
+		" 25% ; allow=alice, bob ":  "25%;allow=alice,bob",
+		"deny=mallory;on":           "on;deny=mallory",
+		"allow=alice;deny=mallory;": "off;allow=alice;deny=mallory",
+	} {
+		f, err := ParseFeatureFlag(s)
+		if err != nil || f.String() != want {
+			t.Errorf("%q should be %q, not %q: %v", s, want, f, err)
+		}
+	}
+
+	for _, s := range []string{"maybe", "101%", "-1%", "x%", "on;50%",
+		"only=alice"} {
+		if _, err := ParseFeatureFlag(s); err == nil {
+			t.Errorf("%q should fail", s)
+		}
+	}
+}
+
+func TestFeatureFlagEnabled(t *testing.T) {
+	ctx := context.Background()
+
+	f, _ := ParseFeatureFlag("on;deny=mallory")
+	if !f.Enabled(ctx, "alice") || f.Enabled(ctx, "mallory") {
+		t.Error("Should be on except for mallory")
+	}
+
+	f, _ = ParseFeatureFlag("off;allow=alice")
+	if !f.Enabled(ctx, "alice") || f.Enabled(ctx, "bob") {
+		t.Error("Should be off except for alice")
+	}
+
+	f, _ = ParseFeatureFlag("25%")
+	f.name = "beta"
+	on := 0
+	for i := 0; i < 10000; i++ {
+		subject := fmt.Sprint("user", i)
+		if f.Enabled(ctx, subject) {
+			on++
+		}
+		if f.Enabled(ctx, subject) != f.Enabled(ctx, subject) {
+			t.Fatal("Should be stable for", subject)
+		}
+	}
+	if on < 2300 || on > 2700 {
+		t.Error("Should be on for about 25% of subjects", on)
+	}
+
+	// Growing the rollout keeps the subjects which had it
+	more, _ := ParseFeatureFlag("50%")
+	more.name = "beta"
+	for i := 0; i < 1000; i++ {
+		subject := fmt.Sprint("user", i)
+		if f.Enabled(ctx, subject) && !more.Enabled(ctx, subject) {
+			t.Fatal("Should stay on for", subject)
+		}
+	}
+
+	if !f.Enabled(ForceFeature(ctx, "beta", true), "user0") ||
+		f.Enabled(ForceFeature(ctx, "beta", false), "user0") {
+		t.Error("Forcing the flag should win")
+	}
+}
+
+type featureTest struct {
+	Beta   FeatureFlag `env_name:"beta" env_def:"off;allow=alice"`
+	Search FeatureFlag `env_name:"search"`
+}
+
+func TestLoadFeatureFlag(t *testing.T) {
+	ctx := context.Background()
+	values := map[string]string{"search": "on"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-beta", "on;deny=mallory"}),
+		WithSources(FlagSource(), MapSource("test", values), DefaultSource()))
+
+	var cfg featureTest
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Beta.Enabled(ctx, "bob") || cfg.Beta.Enabled(ctx, "mallory") ||
+		!cfg.Search.Enabled(ctx, "bob") {
+		t.Error("Unexpected flags", cfg)
+	}
+	if f := fs.Lookup("beta"); f.DefValue != "off;allow=alice" {
+		t.Error("Unexpected default", f.DefValue)
+	}
+	if cfg.Search.Enabled(ForceFeature(ctx, "search", false), "bob") {
+		t.Error("Forcing by the field's name should win")
+	}
+
+	values["search"] = "off"
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Search.Enabled(ctx, "bob") {
+		t.Error("Reload should turn search off")
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", Log(&cfg))
+	if !strings.Contains(buf.String(), `"Beta":"on;deny=mallory"`) {
+		t.Error("Unexpected log", buf.String())
+	}
+
+	values["search"] = "sometimes"
+	if err := l.Reload(); err == nil {
+		t.Error("An invalid flag should fail")
+	}
+}
@@ -165,6 +165,70 @@ func (f *BoolFlag) IsSet() bool {
 	return f.set
 }
 
+// Allows -x as well as -x=true.
+func (f *BoolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Negation of a bool flag (-no-x), setting it sets the bool flag to the
+// opposite value.
+type negatedFlag struct {
+	flag ConfigFlag
+}
+
+func (f *negatedFlag) Set(x string) error {
+	value, err := strconv.ParseBool(x)
+	if err != nil {
+		return err
+	}
+	return f.flag.Set(strconv.FormatBool(!value))
+}
+
+func (f *negatedFlag) String() string {
+	return "false"
+}
+
+func (f *negatedFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Count flag, for int fields tagged env_count. Each -v adds one, -v=3 sets
+// the count. Counting starts from 0, value is the default until it is set.
+type CountFlag struct {
+	set   bool
+	value int
+}
+
+func (f *CountFlag) Set(x string) error {
+	if !f.set {
+		f.value = 0
+	}
+	f.set = true
+	if x == "true" {
+		f.value++
+		return nil
+	}
+	value, err := strconv.ParseInt(x, 0, strconv.IntSize)
+	f.value = int(value)
+	return err
+}
+
+func (f *CountFlag) String() string {
+	return strconv.Itoa(f.value)
+}
+
+func (f *CountFlag) Get() interface{} {
+	return f.value
+}
+
+func (f *CountFlag) IsSet() bool {
+	return f.set
+}
+
+func (f *CountFlag) IsBoolFlag() bool {
+	return true
+}
+
 // Float64 flag
 type Float64Flag struct {
 	set   bool
@@ -190,21 +254,21 @@ func (f *Float64Flag) IsSet() bool {
 	return f.set
 }
 
-// Duration flag
+// Duration flag, accepting days, weeks and ISO-8601 (see ParseDuration)
 type DurationFlag struct {
 	set   bool
 	value time.Duration
 }
 
 func (f *DurationFlag) Set(x string) error {
-	value, err := time.ParseDuration(x)
+	value, err := ParseDuration(x)
 	f.value = value
 	f.set = true
 	return err
 }
 
 func (f *DurationFlag) String() string {
-	return f.value.String()
+	return FormatDuration(f.value)
 }
 
 func (f *DurationFlag) Get() interface{} {
@@ -214,3 +278,139 @@ func (f *DurationFlag) Get() interface{} {
 func (f *DurationFlag) IsSet() bool {
 	return f.set
 }
+
+// ByteSize flag
+type ByteSizeFlag struct {
+	set   bool
+	value ByteSize
+}
+
+func (f *ByteSizeFlag) Set(x string) error {
+	value, err := ParseByteSize(x)
+	f.value = value
+	f.set = true
+	return err
+}
+
+func (f *ByteSizeFlag) String() string {
+	return f.value.String()
+}
+
+func (f *ByteSizeFlag) Get() interface{} {
+	return f.value
+}
+
+func (f *ByteSizeFlag) IsSet() bool {
+	return f.set
+}
+
+// Time flag, parsed with layout (see ParseTime)
+type TimeFlag struct {
+	set    bool
+	value  time.Time
+	layout string
+}
+
+func (f *TimeFlag) Set(x string) error {
+	value, err := ParseTime(x, f.layout)
+	f.value = value
+	f.set = true
+	return err
+}
+
+func (f *TimeFlag) String() string {
+	// The help leaves out the zero time, as it does other zero values
+	if f.value.IsZero() {
+		return ""
+	}
+	if f.layout == "" {
+		return f.value.Format(time.RFC3339Nano)
+	}
+	return f.value.Format(f.layout)
+}
+
+func (f *TimeFlag) Get() interface{} {
+	return f.value
+}
+
+func (f *TimeFlag) IsSet() bool {
+	return f.set
+}
+
+// Location flag, from an IANA time zone name
+type LocationFlag struct {
+	set   bool
+	value *time.Location
+}
+
+func (f *LocationFlag) Set(x string) error {
+	value, err := time.LoadLocation(x)
+	f.value = value
+	f.set = true
+	return err
+}
+
+func (f *LocationFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return f.value.String()
+}
+
+func (f *LocationFlag) Get() interface{} {
+	return f.value
+}
+
+func (f *LocationFlag) IsSet() bool {
+	return f.set
+}
+
+// Weekday flag
+type WeekdayFlag struct {
+	set   bool
+	value time.Weekday
+}
+
+func (f *WeekdayFlag) Set(x string) error {
+	value, err := ParseWeekday(x)
+	f.value = value
+	f.set = true
+	return err
+}
+
+func (f *WeekdayFlag) String() string {
+	return f.value.String()
+}
+
+func (f *WeekdayFlag) Get() interface{} {
+	return f.value
+}
+
+func (f *WeekdayFlag) IsSet() bool {
+	return f.set
+}
+
+// FeatureFlag flag
+type FeatureFlagFlag struct {
+	set   bool
+	value FeatureFlag
+}
+
+func (f *FeatureFlagFlag) Set(x string) error {
+	value, err := ParseFeatureFlag(x)
+	f.value = value
+	f.set = true
+	return err
+}
+
+func (f *FeatureFlagFlag) String() string {
+	return f.value.String()
+}
+
+func (f *FeatureFlagFlag) Get() interface{} {
+	return f.value
+}
+
+func (f *FeatureFlagFlag) IsSet() bool {
+	return f.set
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -214,3 +215,216 @@ func TestDurationFlag(t *testing.T) {
 		t.Error("Flag should be set to 10s")
 	}
 }
+
+func TestByteSizeFlag(t *testing.T) {
+	s := ByteSizeFlag{}
+
+	if s.IsSet() {
+		t.Error("Flag should not be set on initialization")
+	}
+
+	if s.String() != "0B" {
+		t.Error("Flag should initially be 0B")
+	}
+
+	s.Set("1.5GiB")
+
+	if !s.IsSet() {
+		t.Error("Flag should be set")
+	}
+
+	if s.Get() != 3*GiB/2 {
+		t.Error("Flag should be set to 1.5GiB")
+	}
+
+	if s.String() != "1.5GiB" {
+		t.Error("Flag should be set to 1.5GiB")
+	}
+
+	if err := s.Set("lots"); err == nil {
+		t.Error("Flag should not accept lots")
+	}
+}
+
+func TestTimeFlag(t *testing.T) {
+	s := TimeFlag{layout: "2006-01-02"}
+
+	if s.IsSet() {
+		t.Error("Flag should not be set on initialization")
+	}
+
+	s.Set("2024-03-01")
+
+	if !s.IsSet() {
+		t.Error("Flag should be set")
+	}
+
+	if !s.Get().(time.Time).Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Flag should be set to 2024-03-01")
+	}
+
+	if s.String() != "2024-03-01" {
+		t.Error("Flag should be set to 2024-03-01")
+	}
+}
+
+func TestLocationFlag(t *testing.T) {
+	s := LocationFlag{}
+
+	if s.String() != "" {
+		t.Error("Flag should initially be empty")
+	}
+
+	s.Set("UTC")
+
+	if !s.IsSet() || s.Get() != time.UTC {
+		t.Error("Flag should be set to UTC")
+	}
+
+	if err := s.Set("Nowhere/Special"); err == nil {
+		t.Error("Flag should not accept unknown zones")
+	}
+}
+
+func TestWeekdayFlag(t *testing.T) {
+	s := WeekdayFlag{}
+
+	if s.String() != "Sunday" {
+		t.Error("Flag should initially be Sunday")
+	}
+
+	s.Set("fri")
+
+	if !s.IsSet() || s.Get() != time.Friday || s.String() != "Friday" {
+		t.Error("Flag should be set to Friday")
+	}
+}
+
+func TestCountFlag(t *testing.T) {
+	s := CountFlag{}
+
+	if s.IsSet() || s.Get() != 0 {
+		t.Error("Flag should initially be an unset 0")
+	}
+
+	s.Set("true")
+	s.Set("true")
+
+	if !s.IsSet() || s.Get() != 2 || s.String() != "2" {
+		t.Error("Flag should count to 2")
+	}
+
+	s.Set("5")
+
+	if s.Get() != 5 {
+		t.Error("Flag should be set to 5")
+	}
+}
+
+func TestNegatedFlag(t *testing.T) {
+	b := BoolFlag{}
+	s := negatedFlag{&b}
+
+	s.Set("true")
+
+	if !b.IsSet() || b.Get() != false {
+		t.Error("Negation should set the flag to false")
+	}
+
+	s.Set("false")
+
+	if b.Get() != true {
+		t.Error("Negation of false should set the flag to true")
+	}
+}
+
+type negateTest struct {
+	Cache   bool `env_name:"cache" env_def:"true"`
+	Debug   bool `env_name:"debug"`
+	Verbose int  `env_name:"verbose" env_short:"v" env_count:""`
+	Level   int  `env_name:"level" env_def:"1" env_count:""`
+}
+
+func TestNegation(t *testing.T) {
+	var ss negateTest
+	_, _, err := testLoad([]string{"-no-cache"},
+		map[string]string{"debug": "true"}, nil, &ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Cache {
+		t.Error("-no-cache should override the default")
+	}
+
+	ss = negateTest{}
+	_, _, err = testLoad([]string{"--no-debug"},
+		map[string]string{"debug": "true"}, []Option{WithGNUParser()}, &ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Debug {
+		t.Error("--no-debug should override the environment")
+	}
+
+	ss = negateTest{}
+	_, _, err = testLoad([]string{"-debug"}, nil, nil, &ss)
+	if err != nil || !ss.Debug {
+		t.Error("-debug alone should set debug", err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	var ss negateTest
+	_, _, err := testLoad([]string{"-v", "-v", "-verbose"}, nil, nil, &ss)
+	if err != nil || ss.Verbose != 3 {
+		t.Error("-v -v -verbose should count 3", ss.Verbose, err)
+	}
+
+	ss = negateTest{}
+	_, _, err = testLoad([]string{"-vvv", "--verbose"}, nil,
+		[]Option{WithGNUParser()}, &ss)
+	if err != nil || ss.Verbose != 4 {
+		t.Error("-vvv --verbose should count 4", ss.Verbose, err)
+	}
+
+	ss = negateTest{}
+	_, _, err = testLoad(nil, map[string]string{"verbose": "2"}, nil, &ss)
+	if err != nil || ss.Verbose != 2 {
+		t.Error("Count should come from the environment", ss.Verbose, err)
+	}
+
+	ss = negateTest{}
+	_, _, err = testLoad([]string{"-v"}, map[string]string{"verbose": "2"},
+		nil, &ss)
+	if err != nil || ss.Verbose != 1 {
+		t.Error("Count flag should take precedence", ss.Verbose, err)
+	}
+
+	ss = negateTest{}
+	_, _, err = testLoad([]string{"-level", "-level"}, nil, nil, &ss)
+	if err != nil || ss.Level != 2 {
+		t.Error("Counting should not start from the default", ss.Level, err)
+	}
+
+	ss = negateTest{}
+	_, _, err = testLoad(nil, nil, nil, &ss)
+	if err != nil || ss.Level != 1 {
+		t.Error("Level should default to 1", ss.Level, err)
+	}
+}
+
+func TestNegateHelp(t *testing.T) {
+	l, out, err := testLoad(nil, nil, []Option{WithGNUParser()},
+		&negateTest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.PrintDefaults()
+
+	h := out.String()
+	if !strings.Contains(h, "--[no-]cache\n    \tcache (default true)") ||
+		!strings.Contains(h, "-v, --verbose\n    \tverbose (repeatable)") ||
+		strings.Contains(h, "--no-cache") {
+		t.Error("Help should show the negation and counter", h)
+	}
+}
@@ -0,0 +1,286 @@
+/*
+GNU style argument parsing, used by a Loader created WithGNUParser. Long flags
+are given as --name=value or --name value, short flags (from the env_short tag)
+as -p 80 or -p80 and short boolean flags can be combined (-vq). Arguments after
+-- and positional arguments between flags are returned by Args.
+*/
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse the arguments GNU style rather than with the flag package. The help
+// shows the short and long forms of each flag.
+func WithGNUParser() Option {
+	return func(l *Loader) {
+		l.gnu = true
+	}
+}
+
+// The positional arguments left after the flags.
+func (l *Loader) Args() []string {
+	if l.gnu {
+		return l.positional
+	}
+	return l.flags.Args()
+}
+
+// Register the env_short name of fld as another name for its flag.
+func (l *Loader) registerShort(fld *field, tag fieldTags,
+	desc string) error {
+
+	short, ok := tag.Lookup("env_short")
+	if !ok {
+		return nil
+	}
+
+	if utf8.RuneCountInString(short) != 1 {
+		return &TagError{fld.strct, fld.name, "env_short", short,
+			fmt.Errorf("Not a single character")}
+	}
+	if f := l.flags.Lookup(short); f != nil {
+		// A field sharing the name has registered it already
+		if f.Value == fld.value.configFlag() {
+			fld.short = short
+			return nil
+		}
+		return &TagError{fld.strct, fld.name, "env_short", short,
+			fmt.Errorf("Already a flag")}
+	}
+
+	l.flags.Var(fld.value.configFlag(), short, desc)
+	fld.short = short
+	return nil
+}
+
+func isBoolFlag(v flag.Value) bool {
+	b, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// Parse l.args GNU style, setting the flags in l.flags.
+func (l *Loader) parseGNU() error {
+	l.flags.Usage = l.usage
+	l.positional = nil
+
+	args := l.args
+	for len(args) > 0 {
+		a := args[0]
+		args = args[1:]
+
+		switch {
+		case a == "--":
+			l.positional = append(l.positional, args...)
+			args = nil
+
+		case strings.HasPrefix(a, "--"):
+			name, value, hasValue := strings.Cut(a[2:], "=")
+			f, err := l.lookupGNU(name, "--")
+			if err != nil {
+				return l.parseError(err)
+			}
+
+			if !hasValue {
+				if isBoolFlag(f.Value) {
+					value = "true"
+				} else if len(args) == 0 {
+					return l.parseError(fmt.Errorf(
+						"flag needs an argument: --%s", name))
+				} else {
+					value, args = args[0], args[1:]
+				}
+			}
+			if err := l.flags.Set(name, value); err != nil {
+				return l.parseError(l.flagError(name, value, err))
+			}
+
+		case strings.HasPrefix(a, "-") && a != "-":
+			var err error
+			if args, err = l.parseShort(a[1:], args); err != nil {
+				return l.parseError(err)
+			}
+
+		default:
+			l.positional = append(l.positional, a)
+		}
+	}
+
+	// Marks the flags as parsed
+	return l.flags.Parse(nil)
+}
+
+// Parse a group of short flags (-vq, -p80, -p 80, -p=80) returning the
+// remaining args.
+func (l *Loader) parseShort(group string, args []string) ([]string, error) {
+	for group != "" {
+		r, size := utf8.DecodeRuneInString(group)
+		name := string(r)
+		group = group[size:]
+
+		f, err := l.lookupGNU(name, "-")
+		if err != nil {
+			return nil, err
+		}
+
+		var value string
+		switch {
+		case isBoolFlag(f.Value) && !strings.HasPrefix(group, "="):
+			value = "true"
+		case group != "":
+			value, group = strings.TrimPrefix(group, "="), ""
+		case len(args) > 0:
+			value, args = args[0], args[1:]
+		default:
+			return nil, fmt.Errorf("flag needs an argument: -%s", name)
+		}
+
+		if err := l.flags.Set(name, value); err != nil {
+			return nil, l.flagError(name, value, err)
+		}
+	}
+	return args, nil
+}
+
+// Find flag name, given with prefix, -h and --help are flag.ErrHelp unless
+// defined.
+func (l *Loader) lookupGNU(name, prefix string) (*flag.Flag, error) {
+	if f := l.flags.Lookup(name); f != nil {
+		return f, nil
+	}
+	if name == "h" || name == "help" {
+		return nil, flag.ErrHelp
+	}
+
+	known := make(map[string]bool)
+	l.flags.VisitAll(func(f *flag.Flag) {
+		if utf8.RuneCountInString(f.Name) > 1 {
+			known[f.Name] = true
+		}
+	})
+	s := strings.Replace(suggest(name, known), "(did you mean ",
+		"(did you mean --", 1)
+	return nil, fmt.Errorf("flag provided but not defined: %s%s%s", prefix,
+		name, s)
+}
+
+// Handle a parse error as l.flags would, printing the usage and then exiting
+// or panicking if that is its ErrorHandling.
+func (l *Loader) parseError(err error) error {
+	if err != flag.ErrHelp {
+		fmt.Fprintln(l.flags.Output(), err)
+	}
+	l.flags.Usage()
+
+	switch l.flags.ErrorHandling() {
+	case flag.ExitOnError:
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	case flag.PanicOnError:
+		panic(err)
+	}
+	return err
+}
+
+func (l *Loader) usage() {
+	if l.flags.Name() == "" {
+		fmt.Fprintf(l.flags.Output(), "Usage:\n")
+	} else {
+		fmt.Fprintf(l.flags.Output(), "Usage of %s:\n", l.flags.Name())
+	}
+	l.PrintDefaults()
+}
+
+// Print the flags as flag.PrintDefaults does but GNU style, with the short and
+// long forms of each flag (-p, --port value).
+func (l *Loader) PrintDefaults() {
+	l.printDefaults(l.flags.Output())
+}
+
+func (l *Loader) printDefaults(w io.Writer) {
+	shorts := make(map[string]string) // long name to short
+	hidden := make(map[string]bool)   // shown with the long name
+	negatable := make(map[string]bool)
+	for _, f := range l.fields {
+		if f.short != "" {
+			shorts[f.key] = f.short
+			hidden[f.short] = true
+		}
+		if f.negatable {
+			negatable[f.key] = true
+			hidden["no-"+f.key] = true
+		}
+	}
+
+	var flags []*flag.Flag
+	l.flags.VisitAll(func(f *flag.Flag) {
+		if !hidden[f.Name] {
+			flags = append(flags, f)
+		}
+	})
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+
+	for _, f := range flags {
+		var b strings.Builder
+
+		long := f.Name
+		if negatable[f.Name] {
+			long = "[no-]" + f.Name
+		}
+
+		short, hasShort := shorts[f.Name]
+		switch {
+		case hasShort:
+			fmt.Fprintf(&b, "  -%s, --%s", short, long)
+		case utf8.RuneCountInString(f.Name) == 1:
+			fmt.Fprintf(&b, "  -%s", f.Name)
+		default:
+			fmt.Fprintf(&b, "      --%s", long)
+		}
+
+		name, usage := flag.UnquoteUsage(f)
+		if isBoolFlag(f.Value) {
+			name = ""
+		}
+		if name != "" {
+			b.WriteString(" " + name)
+		}
+		b.WriteString("\n    \t")
+		b.WriteString(strings.ReplaceAll(usage, "\n", "\n    \t"))
+		if _, ok := f.Value.(*CountFlag); ok {
+			b.WriteString(" (repeatable)")
+		}
+
+		if !isZeroValue(f) {
+			fmt.Fprintf(&b, " (default %s)", f.DefValue)
+		}
+		fmt.Fprintln(w, b.String())
+	}
+}
+
+// Whether the default of f is the zero value of its type, as the flag package
+// decides whether to show it.
+func isZeroValue(f *flag.Flag) bool {
+	t := reflect.TypeOf(f.Value)
+	var z reflect.Value
+	if t.Kind() == reflect.Ptr {
+		z = reflect.New(t.Elem())
+	} else {
+		z = reflect.Zero(t)
+	}
+	if v, ok := z.Interface().(flag.Value); ok {
+		return f.DefValue == v.String()
+	}
+	return f.DefValue == ""
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type gnuTest struct {
+	Port    int    `env_name:"port" env_short:"p" env_def:"8080" env_desc:"Port to listen on"`
+	Verbose bool   `env_name:"verbose" env_short:"v"`
+	Quiet   bool   `env_name:"quiet" env_short:"q"`
+	Host    string `env_name:"host"`
+}
+
+func TestGNUParse(t *testing.T) {
+	tests := []struct {
+		args []string
+		want gnuTest
+		pos  []string
+	}{
+		{[]string{"--port=80", "--host", "a"}, gnuTest{80, false, false, "a"}, nil},
+		{[]string{"-p", "81", "x"}, gnuTest{81, false, false, ""}, []string{"x"}},
+		{[]string{"-p82"}, gnuTest{82, false, false, ""}, nil},
+		{[]string{"-vqp=83"}, gnuTest{83, true, true, ""}, nil},
+		{[]string{"a", "-v", "b", "--quiet", "--", "-p", "1"},
+			gnuTest{8080, true, true, ""}, []string{"a", "b", "-p", "1"}},
+		{[]string{"--verbose=false", "-"}, gnuTest{8080, false, false, ""},
+			[]string{"-"}},
+	}
+
+	for _, test := range tests {
+		var ss gnuTest
+		l, _, err := testLoad(test.args, nil, []Option{WithGNUParser()}, &ss)
+		if err != nil {
+			t.Error(test.args, err)
+			continue
+		}
+		if ss != test.want {
+			t.Error(test.args, "should give", test.want, "not", ss)
+		}
+		if !reflect.DeepEqual(l.Args(), test.pos) {
+			t.Error(test.args, "should leave", test.pos, "not", l.Args())
+		}
+	}
+}
+
+func TestGNUParseErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--prot=1"}, "flag provided but not defined: --prot (did you mean --port?)"},
+		{[]string{"-x"}, "flag provided but not defined: -x"},
+		{[]string{"--port"}, "flag needs an argument: --port"},
+		{[]string{"-vp"}, "flag needs an argument: -p"},
+		{[]string{"-p", "lots"}, `Invalid value "lots" for p (gnuTest.Port) from flag: strconv.ParseInt: parsing "lots": invalid syntax`},
+		{[]string{"--help"}, flag.ErrHelp.Error()},
+	}
+
+	for _, test := range tests {
+		_, out, err := testLoad(test.args, nil, []Option{WithGNUParser()},
+			&gnuTest{})
+		if err == nil || err.Error() != test.err {
+			t.Error(test.args, "should fail with", test.err, "not", err)
+		}
+		if !strings.Contains(out.String(), "Usage of test:") {
+			t.Error(test.args, "should print the usage", out.String())
+		}
+	}
+}
+
+func TestGNUHelp(t *testing.T) {
+	l, out, err := testLoad(nil, nil, []Option{WithGNUParser()}, &gnuTest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.PrintDefaults()
+	want := `      --host value
+    	host
+  -p, --port value
+    	Port to listen on (default 8080)
+      --profile string
+    	Configuration profile
+  -q, --[no-]quiet
+    	quiet
+  -v, --[no-]verbose
+    	verbose
+`
+	if out.String() != want {
+		t.Error("Help should show both forms", out.String())
+	}
+}
+
+func TestShortStandardParser(t *testing.T) {
+	var ss gnuTest
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-p", "90", "rest"}))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Port != 90 || !reflect.DeepEqual(l.Args(), []string{"rest"}) {
+		t.Error("Short names should work with the flag package", ss, l.Args())
+	}
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HTTPSource looks names up in a JSON object fetched from a URL. The object is
+// fetched on the first Lookup; once watched it is polled every Interval using
+// If-None-Match, backing off up to MaxBackoff while the server is failing.
+// Each fetch is given up after Timeout, so a server which does not respond
+// does not block Load; set it (and the Client's own Timeout, if any) to wait
+// longer.
+type HTTPSource struct {
+	URL        string
+	Client     *http.Client  // http.DefaultClient if nil
+	Interval   time.Duration // 30s if zero
+	MaxBackoff time.Duration // 5m if zero
+	Timeout    time.Duration // 10s if zero
+
+	mu      sync.RWMutex
+	fetched bool
+	etag    string
+	values  map[string]string
+}
+
+// Create a HTTPSource for url using the default client and intervals.
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{URL: url}
+}
+
+func (s *HTTPSource) Name() string {
+	return "http " + s.location()
+}
+
+// The URL without any user name or password, to show in names and errors.
+func (s *HTTPSource) location() string {
+	u, err := url.Parse(s.URL)
+	if err != nil || u.User == nil {
+		return s.URL
+	}
+	u.User = nil
+	return u.String()
+}
+
+func (s *HTTPSource) Lookup(key string) (string, bool, error) {
+	s.mu.RLock()
+	fetched := s.fetched
+	s.mu.RUnlock()
+
+	if !fetched {
+		if _, err := s.Fetch(context.Background()); err != nil {
+			return "", false, err
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+func (s *HTTPSource) keys() ([]string, error) {
+	// Fetches the object if it has not been already
+	if _, _, err := s.Lookup(""); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Fetch the object, returning the keys whose values have changed since the
+// last fetch. Nothing has changed if the server responds 304 Not Modified.
+func (s *HTTPSource) Fetch(ctx context.Context) ([]string, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	s.mu.RLock()
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	s.mu.RUnlock()
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("%s: %s", s.location(), resp.Status)
+	}
+
+	values, err := decodeValues(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.location(), err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []string
+	for k, v := range values {
+		if old, ok := s.values[k]; !ok || old != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range s.values {
+		if _, ok := values[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+
+	s.values = values
+	s.etag = resp.Header.Get("ETag")
+	s.fetched = true
+
+	return changed, nil
+}
+
+// Poll the URL until ctx is done, reporting the keys which change.
+func (s *HTTPSource) Watch(ctx context.Context, changed func(keys []string)) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	maxBackoff := s.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Minute
+	}
+
+	delay := interval
+	var backoff time.Duration
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		keys, err := s.Fetch(ctx)
+		if err != nil {
+			if backoff == 0 {
+				backoff = interval
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			delay = backoff
+		} else {
+			backoff = 0
+			delay = interval
+			if len(keys) > 0 {
+				changed(keys)
+			}
+		}
+
+		timer.Reset(delay)
+	}
+}
+
+// Decode a JSON object into the string representations of its scalar values.
+// Nested objects and arrays are flattened into keys joined by underscores, the
+// way nested and slice fields are named: {"DB": {"Host": "x"}} is DB_Host and
+// {"UPSTREAM": [{"HOST": "x"}]} is UPSTREAM_0_HOST.
+func decodeValues(r io.Reader) (map[string]string, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	var doc map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(doc))
+	for k, v := range doc {
+		if err := flatten(values, k, v); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func flatten(values map[string]string, key string, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+	case string:
+		values[key] = x
+	case json.Number:
+		values[key] = x.String()
+	case bool:
+		values[key] = strconv.FormatBool(x)
+	case map[string]interface{}:
+		for k, v := range x {
+			if err := flatten(values, key+"_"+k, v); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, v := range x {
+			if err := flatten(values, key+"_"+strconv.Itoa(i), v); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("Unsupported value for %s", key)
+	}
+	return nil
+}
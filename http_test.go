@@ -0,0 +1,246 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serves body with an ETag of its version, counting the requests.
+type configServer struct {
+	mu       sync.Mutex
+	version  int
+	body     string
+	status   int
+	requests int
+	notMod   int
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, s.version)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notMod++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, s.body)
+}
+
+func (s *configServer) update(body string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.body = body
+	s.status = status
+}
+
+func TestHTTPSourceLookup(t *testing.T) {
+	cs := &configServer{body: `{"host": "a", "port": 80, "debug": true}`}
+	ts := httptest.NewServer(cs)
+	defer ts.Close()
+
+	s := NewHTTPSource(ts.URL)
+
+	if v, ok, err := s.Lookup("port"); err != nil || !ok || v != "80" {
+		t.Error("port should be 80", v, ok, err)
+	}
+	if v, ok, _ := s.Lookup("debug"); !ok || v != "true" {
+		t.Error("debug should be true", v)
+	}
+	if _, ok, _ := s.Lookup("missing"); ok {
+		t.Error("missing should not be found")
+	}
+	if cs.requests != 1 {
+		t.Error("Should only fetch once", cs.requests)
+	}
+}
+
+func TestHTTPSourceFetchETag(t *testing.T) {
+	cs := &configServer{body: `{"host": "a", "port": 80}`}
+	ts := httptest.NewServer(cs)
+	defer ts.Close()
+
+	s := NewHTTPSource(ts.URL)
+
+	if _, err := s.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.Fetch(context.Background())
+	if err != nil || keys != nil {
+		t.Error("Nothing should have changed", keys, err)
+	}
+	if cs.notMod != 1 {
+		t.Error("Second fetch should be not modified")
+	}
+
+	cs.update(`{"host": "b"}`, 0)
+	keys, err = s.Fetch(context.Background())
+	if err != nil || len(keys) != 2 {
+		t.Error("host and port should have changed", keys, err)
+	}
+}
+
+func TestHTTPSourceError(t *testing.T) {
+	cs := &configServer{status: http.StatusInternalServerError}
+	ts := httptest.NewServer(cs)
+	defer ts.Close()
+
+	s := NewHTTPSource(ts.URL)
+
+	if _, _, err := s.Lookup("host"); err == nil {
+		t.Error("Lookup should fail")
+	}
+
+	cs.update(`["not", "an", "object"]`, 0)
+	if _, err := s.Fetch(context.Background()); err == nil {
+		t.Error("Fetch should fail on a non object")
+	}
+
+	s = NewHTTPSource(strings.Replace(ts.URL, "//", "//admin:s3cret@", 1))
+	if _, err := s.Fetch(context.Background()); err == nil ||
+		strings.Contains(err.Error(), "s3cret") ||
+		strings.Contains(s.Name(), "s3cret") || strings.Contains(s.Name(), "admin") {
+		t.Error("The user and password should not be shown", s.Name(), err)
+	}
+}
+
+func TestHTTPSourceTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+	defer ts.Close()
+	defer close(done)
+
+	s := NewHTTPSource(ts.URL)
+	s.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if _, _, err := s.Lookup("host"); err == nil {
+		t.Error("Lookup should time out")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Error("Lookup took", d)
+	}
+}
+
+func TestHTTPSourcePrecedence(t *testing.T) {
+	cs := &configServer{body: `{"host": "remote", "port": 80}`}
+	ts := httptest.NewServer(cs)
+	defer ts.Close()
+
+	ss := struct {
+		Host string `env_name:"host" env_def:"local"`
+		Port int    `env_name:"port" env_def:"8080"`
+	}{}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-port", "90"}),
+		WithSource(NewHTTPSource(ts.URL), 1))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Host != "remote" {
+		t.Error("Host should come from http", ss.Host)
+	}
+	if ss.Port != 90 {
+		t.Error("Port flag should take precedence", ss.Port)
+	}
+}
+
+func TestHTTPSourceWatch(t *testing.T) {
+	cs := &configServer{body: `{"host": "a"}`}
+	ts := httptest.NewServer(cs)
+	defer ts.Close()
+
+	ss := struct {
+		Host string `env_name:"host"`
+	}{}
+
+	s := NewHTTPSource(ts.URL)
+	s.Interval = time.Millisecond
+	s.MaxBackoff = 4 * time.Millisecond
+
+	reloaded := make(chan error, 10)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithSource(s, 0),
+		OnReload(func(err error) { reloaded <- err }))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l.Watch(ctx)
+
+	cs.update("", http.StatusServiceUnavailable)
+	time.Sleep(10 * time.Millisecond)
+	cs.update(`{"host": "b"}`, 0)
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Should have reloaded")
+	}
+
+	l.mu.Lock()
+	host := ss.Host
+	l.mu.Unlock()
+	if host != "b" {
+		t.Error("Host should have been reloaded", host)
+	}
+}
+
+func TestHTTPSourceWatchChanges(t *testing.T) {
+	cs := &configServer{body: `{"host": "a", "port": 80}`}
+	ts := httptest.NewServer(cs)
+	defer ts.Close()
+
+	s := NewHTTPSource(ts.URL)
+	s.Interval = time.Millisecond
+	s.MaxBackoff = 4 * time.Millisecond
+	if _, err := s.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan []string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx, func(keys []string) { changed <- keys })
+
+	cs.update("", http.StatusServiceUnavailable)
+	time.Sleep(10 * time.Millisecond)
+	cs.update(`{"host": "b", "port": 80}`, 0)
+
+	select {
+	case keys := <-changed:
+		if len(keys) != 1 || keys[0] != "host" {
+			t.Error("Only host should have changed", keys)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Should have reported the change")
+	}
+}
@@ -0,0 +1,333 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A hierarchical key/value store (etcd, Consul etc.) with keys like
+// "/app/db/host". Clients are adapted to this to be used with KVSource.
+type KVStore interface {
+	// Returns the value for key and whether there is one.
+	Get(key string) (string, bool, error)
+	// Returns all the keys and values under prefix.
+	List(prefix string) (map[string]string, error)
+	// Blocks until ctx is done, calling changed with each key under prefix
+	// which is set or deleted.
+	Watch(ctx context.Context, prefix string, changed func(key string)) error
+}
+
+// KVSource looks fields up in store by their path below prefix, the field
+// Host of the struct DB nested in a loaded struct is prefix + "/db/host".
+// Watching the source only reloads the fields whose keys change.
+func KVSource(store KVStore, prefix string) Source {
+	return &kvSource{
+		store:  store,
+		prefix: strings.TrimSuffix(prefix, "/"),
+		keys:   make(map[string]string),
+	}
+}
+
+type kvSource struct {
+	store  KVStore
+	prefix string
+
+	mu   sync.Mutex
+	keys map[string]string // store key to flag/env name
+}
+
+func (s *kvSource) Name() string {
+	return "kv " + s.prefix
+}
+
+// Looking up by flag/env name is used for fields outside of a Loader.
+func (s *kvSource) Lookup(key string) (string, bool, error) {
+	return s.store.Get(s.prefix + "/" + strings.ToLower(key))
+}
+
+// Aliases are not looked up in the store.
+func (s *kvSource) bind(f *field, key string) Source {
+	if key != f.key {
+		return mapSource{s.Name(), nil}
+	}
+
+	path := f.path
+	if path == nil {
+		path = []string{f.key}
+	}
+	storeKey := s.prefix + "/" + strings.ToLower(strings.Join(path, "/"))
+
+	s.mu.Lock()
+	s.keys[storeKey] = f.key
+	s.mu.Unlock()
+
+	return boundKV{s, storeKey}
+}
+
+// The store is watched again after kvRetry if it fails.
+func (s *kvSource) Watch(ctx context.Context, changed func(keys []string)) {
+	for {
+		err := s.store.Watch(ctx, s.prefix+"/", func(key string) {
+			s.mu.Lock()
+			name, ok := s.keys[key]
+			s.mu.Unlock()
+
+			if ok {
+				changed([]string{name})
+			}
+		})
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		defaultLogger.Printf("config: watching %s: %v", s.Name(), err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(kvRetry):
+		}
+	}
+}
+
+// How long to wait before watching a store which failed again.
+const kvRetry = time.Second
+
+type boundKV struct {
+	s   *kvSource
+	key string
+}
+
+func (b boundKV) Name() string {
+	return b.s.Name()
+}
+
+func (b boundKV) Lookup(key string) (string, bool, error) {
+	return b.s.store.Get(b.key)
+}
+
+// An in memory KVStore.
+type MemoryKV struct {
+	mu       sync.Mutex
+	values   map[string]string
+	watchers map[*kvWatcher]bool
+}
+
+type kvWatcher struct {
+	prefix  string
+	changed func(key string)
+}
+
+// Create an empty MemoryKV.
+func NewMemoryKV() *MemoryKV {
+	return &MemoryKV{
+		values:   make(map[string]string),
+		watchers: make(map[*kvWatcher]bool),
+	}
+}
+
+func (kv *MemoryKV) Get(key string) (string, bool, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	v, ok := kv.values[key]
+	return v, ok, nil
+}
+
+func (kv *MemoryKV) List(prefix string) (map[string]string, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	values := make(map[string]string)
+	for k, v := range kv.values {
+		if strings.HasPrefix(k, prefix) {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+func (kv *MemoryKV) Watch(ctx context.Context, prefix string,
+	changed func(key string)) error {
+
+	w := &kvWatcher{prefix, changed}
+
+	kv.mu.Lock()
+	kv.watchers[w] = true
+	kv.mu.Unlock()
+
+	<-ctx.Done()
+
+	kv.mu.Lock()
+	delete(kv.watchers, w)
+	kv.mu.Unlock()
+
+	return nil
+}
+
+// Set key to value, notifying the watchers if it has changed.
+func (kv *MemoryKV) Put(key, value string) {
+	kv.mu.Lock()
+	old, ok := kv.values[key]
+	kv.values[key] = value
+	kv.mu.Unlock()
+
+	if !ok || old != value {
+		kv.notify(key)
+	}
+}
+
+// Remove key, notifying the watchers if it was set.
+func (kv *MemoryKV) Delete(key string) {
+	kv.mu.Lock()
+	_, ok := kv.values[key]
+	delete(kv.values, key)
+	kv.mu.Unlock()
+
+	if ok {
+		kv.notify(key)
+	}
+}
+
+// Watchers are called without the lock held so they can Get the new value.
+func (kv *MemoryKV) notify(key string) {
+	kv.mu.Lock()
+	var fns []func(key string)
+	for w := range kv.watchers {
+		if strings.HasPrefix(key, w.prefix) {
+			fns = append(fns, w.changed)
+		}
+	}
+	kv.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(key)
+	}
+}
+
+// A KVStore read from a JSON file of nested objects, {"app": {"db": {"host":
+// "x"}}} is the key "/app/db/host". Watching polls the file every Interval,
+// logging the errors reading it and keeping the values last read.
+type FileKV struct {
+	*MemoryKV
+	Path     string
+	Interval time.Duration // 1s if zero
+	Logger   Logger        // the standard logger if nil
+
+	readMu  sync.Mutex
+	modTime time.Time
+}
+
+// Read the FileKV at path.
+func NewFileKV(path string) (*FileKV, error) {
+	kv := &FileKV{MemoryKV: NewMemoryKV(), Path: path}
+	if err := kv.read(); err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+func (kv *FileKV) Watch(ctx context.Context, prefix string,
+	changed func(key string)) error {
+
+	interval := kv.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	go kv.MemoryKV.Watch(ctx, prefix, changed)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := kv.read(); err != nil {
+				logger := kv.Logger
+				if logger == nil {
+					logger = defaultLogger
+				}
+				logger.Printf("config: %v", err)
+			}
+		}
+	}
+}
+
+// Read the file if it has been modified, updating the values in memory.
+func (kv *FileKV) read() error {
+	kv.readMu.Lock()
+	defer kv.readMu.Unlock()
+
+	info, err := os.Stat(kv.Path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(kv.modTime) {
+		return nil
+	}
+
+	b, err := os.ReadFile(kv.Path)
+	if err != nil {
+		return err
+	}
+
+	// Numbers are kept as written, large integers would be formatted with an
+	// exponent as float64s
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var doc map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		return fmt.Errorf("%s: %v", kv.Path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flattenKV(values, "", doc); err != nil {
+		return fmt.Errorf("%s: %v", kv.Path, err)
+	}
+
+	old, _ := kv.List("")
+	for k := range old {
+		if _, ok := values[k]; !ok {
+			kv.Delete(k)
+		}
+	}
+	for k, v := range values {
+		kv.Put(k, v)
+	}
+
+	kv.modTime = info.ModTime()
+	return nil
+}
+
+func flattenKV(values map[string]string, prefix string,
+	doc map[string]interface{}) error {
+
+	for k, v := range doc {
+		key := prefix + "/" + k
+		switch x := v.(type) {
+		case nil:
+		case string:
+			values[key] = x
+		case json.Number:
+			values[key] = x.String()
+		case bool:
+			values[key] = strconv.FormatBool(x)
+		case map[string]interface{}:
+			if err := flattenKV(values, key, x); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unsupported value for %s", key)
+		}
+	}
+	return nil
+}
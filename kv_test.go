@@ -0,0 +1,299 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryKV(t *testing.T) {
+	kv := NewMemoryKV()
+	kv.Put("/app/a", "1")
+	kv.Put("/app/b/c", "2")
+	kv.Put("/other", "3")
+
+	if v, ok, _ := kv.Get("/app/b/c"); !ok || v != "2" {
+		t.Error("/app/b/c should be 2")
+	}
+
+	if l, _ := kv.List("/app/"); len(l) != 2 {
+		t.Error("Should list the two keys under /app/", l)
+	}
+
+	kv.Delete("/app/a")
+	if _, ok, _ := kv.Get("/app/a"); ok {
+		t.Error("/app/a should have been deleted")
+	}
+}
+
+type kvDB struct {
+	Host string
+	Port int `env_def:"5432"`
+}
+
+type kvApp struct {
+	Name string
+	DB   kvDB `env_name:"db"`
+}
+
+func TestKVSourceNested(t *testing.T) {
+	kv := NewMemoryKV()
+	kv.Put("/app/name", "test")
+	kv.Put("/app/db/host", "db.local")
+
+	var app kvApp
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-db_Port", "1"}),
+		WithSource(KVSource(kv, "/app"), 1))
+
+	if err := l.Load(&app); err != nil {
+		t.Fatal(err)
+	}
+
+	if app.Name != "test" || app.DB.Host != "db.local" || app.DB.Port != 1 {
+		t.Error("Nested fields should be set", app)
+	}
+
+	if p := l.Provenance(); p["kvApp.DB.Host"] != "kv /app" {
+		t.Error("Host should come from the kv store", p)
+	}
+}
+
+func TestKVSourceWatch(t *testing.T) {
+	kv := NewMemoryKV()
+	kv.Put("/app/name", "a")
+	kv.Put("/app/db/host", "b")
+
+	var app kvApp
+
+	reloaded := make(chan error, 10)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithSource(KVSource(kv, "/app"), 0),
+		OnReload(func(err error) { reloaded <- err }))
+
+	if err := l.Load(&app); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l.Watch(ctx)
+
+	// Wait for the watch to be registered
+	for i := 0; i < 100; i++ {
+		kv.mu.Lock()
+		n := len(kv.watchers)
+		kv.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	l.mu.Lock()
+	app.Name = "unchanged"
+	l.mu.Unlock()
+
+	kv.Put("/app/db/host", "c")
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Should have reloaded")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if app.DB.Host != "c" {
+		t.Error("Host should have been reloaded", app.DB.Host)
+	}
+	if app.Name != "unchanged" {
+		t.Error("Only the changed field should be reloaded", app.Name)
+	}
+}
+
+func TestKVSourceDeleted(t *testing.T) {
+	kv := NewMemoryKV()
+	kv.Put("/app/name", "a")
+	kv.Put("/app/db/port", "2")
+
+	var app kvApp
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithSource(KVSource(kv, "/app"), 0))
+	if err := l.Load(&app); err != nil {
+		t.Fatal(err)
+	}
+
+	kv.Delete("/app/name")
+	kv.Delete("/app/db/port")
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if app.Name != "" || app.DB.Port != 5432 {
+		t.Error("Deleted keys should fall back to the default or zero", app)
+	}
+	if p := l.Provenance(); p["kvApp.Name"] != "" ||
+		p["kvApp.DB.Port"] != "default" {
+		t.Error("Unexpected provenance", p)
+	}
+}
+
+func TestFileKV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kv.json")
+	if err := os.WriteFile(path, []byte(`{"app": {"db": {"host": "a", "port": 1, "pool": 104857600}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kv, err := NewFileKV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv.Interval = time.Millisecond
+
+	if v, ok, _ := kv.Get("/app/db/port"); !ok || v != "1" {
+		t.Error("/app/db/port should be 1", v)
+	}
+	if v, _, _ := kv.Get("/app/db/pool"); v != "104857600" {
+		t.Error("Large integers should be kept as written", v)
+	}
+
+	changed := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go kv.Watch(ctx, "/app/", func(key string) { changed <- key })
+	time.Sleep(5 * time.Millisecond)
+
+	if err := os.WriteFile(path, []byte(`{"app": {"db": {"host": "b", "port": 1, "pool": 104857600}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(path, future, future)
+
+	select {
+	case key := <-changed:
+		if key != "/app/db/host" {
+			t.Error("Only host should change", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Should have seen the change")
+	}
+}
+
+func TestFileKVErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kv.json")
+	if err := os.WriteFile(path, []byte(`{"app": {"host": "a"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kv, err := NewFileKV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	kv.Interval = time.Millisecond
+	kv.Logger = logger
+
+	changed := make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go kv.Watch(ctx, "/app/", func(key string) { changed <- key })
+
+	// A bad file is logged and the values kept until it is fixed
+	write := func(doc string, mod time.Time) {
+		if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mod, mod)
+	}
+	write(`{"app": `, time.Now().Add(time.Hour))
+	for i := 0; i < 1000; i++ {
+		logger.mu.Lock()
+		n := len(logger.messages)
+		logger.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v, _, _ := kv.Get("/app/host"); v != "a" {
+		t.Error("The last values should be kept", v)
+	}
+
+	write(`{"app": {"host": "b"}}`, time.Now().Add(2*time.Hour))
+	select {
+	case key := <-changed:
+		if key != "/app/host" {
+			t.Error("Only host should change", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Should keep polling after an error")
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.messages) == 0 ||
+		!strings.Contains(logger.messages[0], "kv.json") {
+		t.Error("The error should be logged", logger.messages)
+	}
+}
+
+// Fails the first Watch.
+type failingKV struct {
+	*MemoryKV
+	failed bool
+}
+
+func (kv *failingKV) Watch(ctx context.Context, prefix string,
+	changed func(key string)) error {
+
+	if !kv.failed {
+		kv.failed = true
+		return errors.New("connection refused")
+	}
+	return kv.MemoryKV.Watch(ctx, prefix, changed)
+}
+
+func TestKVSourceWatchRetry(t *testing.T) {
+	kv := &failingKV{MemoryKV: NewMemoryKV()}
+	kv.Put("/app/name", "a")
+
+	var app kvApp
+	reloaded := make(chan error, 10)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithSource(KVSource(kv, "/app"), 0),
+		OnReload(func(err error) { reloaded <- err }))
+	if err := l.Load(&app); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l.Watch(ctx)
+
+	// Keep changing the value until the retried watch sees it
+	deadline := time.Now().Add(3 * kvRetry)
+	for i := 0; ; i++ {
+		kv.Put("/app/name", fmt.Sprint("b", i))
+		select {
+		case err := <-reloaded:
+			if err != nil {
+				t.Error(err)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Should watch again after the store fails")
+		}
+	}
+}
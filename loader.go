@@ -0,0 +1,649 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A Loader initializes configuration structures from a chain of Sources. The
+// chain starts as flags, environment then defaults (the env_def tag); it can be
+// replaced with WithSources and other sources added at any position with
+// WithSource.
+type Loader struct {
+	flags    *flag.FlagSet
+	args     []string
+	sources  []Source
+	onReload func(err error)
+
+	logger     Logger
+	lookupEnv  func(key string) (string, bool)
+	environ    func() []string
+	profile    string
+	profileEnv string
+	envPrefix  string
+	unknown    UnknownMode
+	gnu        bool
+	positional []string
+	expvar     string
+	published  bool
+	keys       [][]byte // decrypt values, the first is current
+	keyFile    string
+	keyEnv     string
+	keysRead   bool
+
+	mu       sync.Mutex
+	structs  []interface{}
+	elements []element // of slice and map fields
+	fields   []*field
+	hooks    []func() // called with mu held after every successful set
+	checks   []check  // constraints between fields
+}
+
+// A field of a configuration struct and the value used to set it.
+type field struct {
+	strct  string   // name of the struct type
+	name   string   // name of the struct field, dotted if nested
+	path   []string // names of the field and the structs it is nested in
+	key    string   // flag/env name
+	value  chainValue
+	vt     valueTags
+	parent interface{} // pointer to the struct
+	source string      // name of the source which set the field
+
+	defSource string                 // the default's source if not "default"
+	defFunc   func() (string, error) // computes the default (env_def_func)
+
+	aliases      []alias
+	short        string // env_short name
+	negatable    bool   // has a -no-<key> flag
+	deprecated   string // env_deprecated message
+	isDeprecated bool
+	warned       bool   // using a deprecated name has been logged
+	desc         string // env_desc, if given
+	secret       bool   // env_secret, never shown
+	mutable      bool   // env_mutable, can be changed by an AdminHandler
+}
+
+// Option configures a Loader.
+type Option func(l *Loader)
+
+// Use fs and args instead of flag.CommandLine and os.Args[1:].
+func WithFlagSet(fs *flag.FlagSet, args []string) Option {
+	return func(l *Loader) {
+		l.flags = fs
+		l.args = args
+	}
+}
+
+// Use sources, in order, as the source chain. FlagSource, EnvSource and
+// DefaultSource give the built-in behaviour.
+func WithSources(sources ...Source) Option {
+	return func(l *Loader) {
+		l.sources = append([]Source(nil), sources...)
+	}
+}
+
+// Insert s into the source chain at position (0 is consulted first). A
+// position past the end of the chain appends s.
+func WithSource(s Source, position int) Option {
+	return func(l *Loader) {
+		if position < 0 {
+			position = 0
+		}
+		if position > len(l.sources) {
+			position = len(l.sources)
+		}
+		l.sources = append(l.sources[:position],
+			append([]Source{s}, l.sources[position:]...)...)
+	}
+}
+
+// Use lookup instead of os.LookupEnv for the environment, both for the
+// EnvSource(nil) in the chain and for the profile. The environment can not be
+// checked for unknown names.
+func WithEnv(lookup func(key string) (string, bool)) Option {
+	return func(l *Loader) {
+		l.lookupEnv = lookup
+		l.environ = nil
+	}
+}
+
+// Use env as the environment instead of the process's.
+func WithEnvMap(env map[string]string) Option {
+	return func(l *Loader) {
+		l.lookupEnv = func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}
+		l.environ = func() []string {
+			var environ []string
+			for k, v := range env {
+				environ = append(environ, k+"="+v)
+			}
+			return environ
+		}
+	}
+}
+
+// Called after every reload triggered by a Watcher with the result.
+func OnReload(fn func(err error)) Option {
+	return func(l *Loader) {
+		l.onReload = fn
+	}
+}
+
+// Create a Loader for the command line configured by opts.
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{
+		flags:   flag.CommandLine,
+		args:    os.Args[1:],
+		sources: []Source{flagSource{}, envSource{}, defaultSource{}},
+
+		logger:     defaultLogger,
+		lookupEnv:  os.LookupEnv,
+		environ:    os.Environ,
+		profileEnv: "APP_PROFILE",
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Pass a point to the annotated structures you want to initialize
+func (l *Loader) Load(structs ...interface{}) error {
+	targets := make([]target, len(structs))
+	for i, s := range structs {
+		v := reflect.ValueOf(s)
+		if v.Kind() != reflect.Ptr || v.IsNil() ||
+			v.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("Load needs pointers to structs, not %T", s)
+		}
+
+		t := v.Elem()
+		p := planFor(t.Type())
+		targets[i] = target{s, t.Type().Name(), p, func(j int) interface{} {
+			return t.FieldByIndex(p.fields[j].index).Addr().Interface()
+		}}
+	}
+	return l.load(targets)
+}
+
+// A struct to load with its plan. fieldOf gives the pointer to the field at
+// an index of the plan's fields.
+type target struct {
+	s       interface{}
+	name    string // of the struct type
+	p       *plan
+	fieldOf func(i int) interface{}
+}
+
+// Register the fields of targets, then parse the command line and set them.
+func (l *Loader) load(targets []target) error {
+
+	if l.flags.Parsed() {
+		return fmt.Errorf("Load must be called before a call to flag.Pars")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := make(map[string]ConfigFlag)
+
+	if err := l.readKeys(); err != nil {
+		return err
+	}
+	l.setProfile(targets)
+	for i, s := range l.sources {
+		switch x := s.(type) {
+		case envSource:
+			if x.lookup == nil {
+				l.sources[i] = envSource{l.lookupEnv}
+			}
+		case profiled:
+			x.setProfile(l.profile)
+		}
+	}
+
+	var errs []error
+	for _, t := range targets {
+		callDefaults(t.s)
+		errs = append(errs, l.register(m, t.s, t.name, t.p, t.fieldOf,
+			isDefaults(t.s))...)
+	}
+	if err := joinErrors(errs); err != nil {
+		return err
+	}
+	for _, t := range targets {
+		l.structs = append(l.structs, t.s)
+	}
+	l.registerProfile()
+
+	if l.gnu {
+		if err := l.parseGNU(); err != nil {
+			return err
+		}
+	} else {
+		if err := l.parseFlags(); err != nil {
+			return err
+		}
+	}
+	if err := l.checkUnknown(); err != nil {
+		return err
+	}
+
+	if err := l.set(nil); err != nil {
+		return err
+	}
+	l.publish()
+	return nil
+}
+
+// Register the fields of the struct strct from its plan, fieldOf gives the
+// pointer to each. With defaults the fields already set are defaults.
+// Returns an error for each field which could not be registered.
+func (l *Loader) register(m map[string]ConfigFlag, s interface{},
+	strct string, p *plan, fieldOf func(i int) interface{},
+	defaults bool) []error {
+
+	var errs []error
+	fields := make([]*field, len(p.fields))
+
+	// Computed defaults are registered last, once the other fields hold their
+	// defaults for the functions to use
+	order := make([]int, 0, len(p.fields))
+	for _, funcs := range []bool{false, true} {
+		for i, pf := range p.fields {
+			if pf.isDefFunc == funcs {
+				order = append(order, i)
+			}
+		}
+	}
+	applied := false
+
+	for _, i := range order {
+		pf := p.fields[i]
+		if pf.isDefFunc && !applied {
+			applyDefaults(fields)
+			applied = true
+		}
+		if pf.err != nil {
+			errs = append(errs, &FieldError{Struct: strct, Field: pf.name,
+				Name: pf.key, Err: pf.err})
+			continue
+		}
+		ptr := fieldOf(i)
+		x := deref(ptr)
+		if x == nil {
+			// Not a supported type, slices and maps of structs are reflected on
+			f := reflect.ValueOf(ptr).Elem()
+			if isStructSlice(f.Type()) {
+				errs = append(errs, l.registerSlice(m, s, strct, pf, f,
+					defaults)...)
+				continue
+			}
+			if isStructMap(f.Type()) {
+				errs = append(errs, l.registerMap(m, s, strct, pf, f,
+					defaults)...)
+				continue
+			}
+		}
+		tag := pf.tag
+
+		defVal, isDefVal := pf.def, pf.isDef
+		// Parsed when the plan was made unless the default is replaced
+		def, parsed := pf.defValue, pf.parsed
+		var defSource string
+		if l.profile != "" {
+			if v, ok := tag.Lookup("env_def_" + l.profile); ok {
+				defVal, isDefVal = v, true
+				defSource = "default " + l.profile
+				parsed = false
+			}
+		}
+
+		// Set by the Defaults method
+		if defaults && x != nil && !isZero(x) {
+			defVal, isDefVal = formatValue(x, pf.vt), true
+			defSource = "Defaults"
+			parsed = false
+		}
+
+		// Decrypted when the field is set so the help does not show it
+		var defFunc func() (string, error)
+		if isDefVal && IsEncrypted(defVal) {
+			encrypted := defVal
+			defFunc = func() (string, error) { return encrypted, nil }
+			defVal, isDefVal = "", false
+			parsed = false
+		}
+
+		if pf.isDefFunc {
+			name := pf.defFunc
+			fn, ok := lookupDefaultFunc(name)
+			if !ok || pf.isDef {
+				err := fmt.Errorf("No default function registered with the name")
+				if ok {
+					err = fmt.Errorf("env_def is given too")
+				}
+				errs = append(errs, &TagError{strct, pf.name, "env_def_func",
+					name, err})
+				continue
+			}
+
+			defFunc = func() (string, error) { return fn(s) }
+			defSource = "default " + name + "()"
+			var err error
+			if defVal, err = defFunc(); err != nil {
+				errs = append(errs, &FieldError{Struct: strct, Field: pf.name,
+					Name: pf.key, Source: defSource, Err: err})
+				continue
+			}
+			isDefVal = true
+			parsed = false
+		}
+
+		var err error
+		if !parsed {
+			def = nil
+			if isDefVal {
+				def, err = parseDefVal(ptr, pf.vt, defVal)
+			}
+		}
+		var v SetValue
+		if err == nil {
+			v, err = newValue(l.flags, m, ptr, pf.vt, pf.key, def, pf.desc)
+		}
+
+		if err != nil {
+			if defSource == "" {
+				defSource = "default"
+			}
+			if pf.secret && defVal != "" {
+				err = &redactedError{err, defVal}
+				defVal = redacted
+			}
+			errs = append(errs, registerError(err, strct, pf.name, pf.key,
+				defSource, defVal))
+			continue
+		}
+
+		fld := &field{
+			strct:  strct,
+			name:   pf.name,
+			path:   pf.path,
+			key:    pf.key,
+			value:  v.(chainValue),
+			vt:     pf.vt,
+			parent: s,
+
+			defSource:    defSource,
+			defFunc:      defFunc,
+			deprecated:   pf.deprecated,
+			isDeprecated: pf.isDeprecated,
+			mutable:      pf.mutable,
+			desc:         pf.envDesc,
+			secret:       pf.secret,
+		}
+		if err := l.checkShared(fld); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := l.registerAliases(m, ptr, fld, tag,
+			pf.path[:len(pf.path)-1]); err != nil {
+			errs = append(errs, registerError(err, strct, pf.name, pf.key,
+				"", ""))
+			continue
+		}
+		if err := l.checkAliases(fld); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if p.element && !p.noDotted {
+			if err := l.registerDotted(m, ptr, fld); err != nil {
+				errs = append(errs, registerError(err, strct, pf.name, pf.key,
+					"", ""))
+				continue
+			}
+		}
+		if err := l.registerShort(fld, tag, pf.desc); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		l.registerNegation(fld)
+		if fld.secret && isDefVal {
+			l.redactDefault(fld)
+		}
+		l.fields = append(l.fields, fld)
+		fields[i] = fld
+	}
+
+	return append(errs, l.registerConstraints(s, strct, p, fields)...)
+}
+
+// Set the fields which have defaults to them.
+func applyDefaults(fields []*field) {
+	for _, fld := range fields {
+		if fld == nil {
+			continue
+		}
+		if _, ok := fld.value.defaultString(); ok {
+			fld.value.reset()
+		}
+	}
+}
+
+// Show the default of a secret field as redacted in the help.
+func (l *Loader) redactDefault(fld *field) {
+	for _, name := range []string{fld.key, fld.short} {
+		if f := l.flags.Lookup(name); f != nil {
+			f.DefValue = redacted
+		}
+	}
+}
+
+// Register -no-<key> for bool fields, unless the name is taken, to turn them
+// off when the environment or a file has turned them on.
+func (l *Loader) registerNegation(fld *field) {
+	if _, ok := fld.value.(*BoolValue); !ok {
+		return
+	}
+
+	name := "no-" + fld.key
+	if l.flags.Lookup(name) != nil {
+		return
+	}
+	l.flags.Var(&negatedFlag{fld.value.configFlag()}, name,
+		"Set -"+fld.key+" to false")
+	fld.negatable = true
+}
+
+// Structs other than the supported value types are nested configuration.
+func isNested(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && t != featureFlagType
+}
+
+// Resolve all the fields again, flags are not reparsed.
+func (l *Loader) Reload() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.set(nil)
+}
+
+// The name of the source which set each field, keyed by struct and field name
+// (e.g. "Server.Port"). Fields no source had a value for are missing.
+func (l *Loader) Provenance() map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p := make(map[string]string)
+	for _, f := range l.fields {
+		if f.source != "" {
+			p[f.strct+"."+f.name] = f.source
+		}
+	}
+	return p
+}
+
+// Start the Watchers in the source chain, reloading the fields whose keys they
+// report as changed. Returns immediately, the watchers stop when ctx is done.
+func (l *Loader) Watch(ctx context.Context) {
+	for _, s := range l.sources {
+		if w, ok := s.(Watcher); ok {
+			go w.Watch(ctx, l.changed)
+		}
+	}
+}
+
+func (l *Loader) changed(keys []string) {
+	l.mu.Lock()
+	err := l.set(keys)
+	l.mu.Unlock()
+
+	if l.onReload != nil {
+		l.onReload(err)
+	}
+}
+
+// Set the fields with the given keys (all if nil) then validate and
+// initialize the structs. Every field and struct which fails is reported.
+func (l *Loader) set(keys []string) error {
+	var errs []error
+	for _, f := range l.fields {
+		if keys != nil && !f.hasKey(keys) {
+			continue
+		}
+
+		src, err := resolve(f, l.sources, l.logger, l.keys)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		f.source = src
+	}
+	if err := joinErrors(errs); err != nil {
+		return err
+	}
+
+	for _, e := range l.elements {
+		errs = append(errs, l.validate(e.s, e.name)...)
+		if e.store != nil {
+			e.store()
+		}
+	}
+	for _, s := range l.structs {
+		errs = append(errs, l.validate(s, reflect.TypeOf(s).Elem().Name())...)
+	}
+	if err := joinErrors(errs); err != nil {
+		return err
+	}
+
+	for _, hook := range l.hooks {
+		hook()
+	}
+
+	return nil
+}
+
+// Parse l.args as the flag package does, setting the flags in l.flags. An
+// unknown flag close to a known one is reported with a suggestion and an
+// invalid value as a FieldError.
+func (l *Loader) parseFlags() error {
+	args := l.args
+	for len(args) > 0 {
+		a := args[0]
+		if len(a) < 2 || a[0] != '-' || a == "--" {
+			break // left to the flag package, which drops --
+		}
+		args = args[1:]
+
+		name := strings.TrimPrefix(a[1:], "-")
+		if name == "" || name[0] == '-' || name[0] == '=' {
+			return l.parseError(fmt.Errorf("bad flag syntax: %s", a))
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+
+		f := l.flags.Lookup(name)
+		if f == nil {
+			if name == "help" || name == "h" {
+				return l.parseError(flag.ErrHelp)
+			}
+			return l.parseError(l.undefinedFlag(name))
+		}
+		if !hasValue {
+			if isBoolFlag(f.Value) {
+				value = "true"
+			} else if len(args) == 0 {
+				return l.parseError(fmt.Errorf("flag needs an argument: -%s",
+					name))
+			} else {
+				value, args = args[0], args[1:]
+			}
+		}
+		if err := l.flags.Set(name, value); err != nil {
+			return l.parseError(l.flagError(name, value, err))
+		}
+	}
+
+	// Marks the flags as parsed and keeps the remaining arguments
+	return l.flags.Parse(args)
+}
+
+// The FieldError for the invalid value of flag name, redacted if the flag is
+// one of a secret field's names.
+func (l *Loader) flagError(name, value string, err error) error {
+	var fld *field
+	for _, f := range l.fields {
+		if f.short == name || f.negatable && name == "no-"+f.key ||
+			contains(f.keys(), name) {
+			if fld == nil || f.secret {
+				fld = f
+			}
+		}
+	}
+	if fld == nil {
+		return &FieldError{Name: name, Source: "flag", Value: value, Err: err}
+	}
+	return fld.fieldError("flag", name, value, err)
+}
+
+// Check the constraints of struct s, named name in errors, then validate and
+// initialize it.
+func (l *Loader) validate(s interface{}, name string) []error {
+	if errs := l.checkConstraints(s); errs != nil {
+		return errs
+	}
+
+	if v, ok := s.(Validate); ok {
+		if err := v.Validate(); err != nil {
+			return []error{&ValidationError{name, err}}
+		}
+	}
+
+	if v, ok := s.(Initialize); ok {
+		v.Initialize()
+	}
+	return nil
+}
+
+// Call hook after every load and reload, with the structs locked so they are
+// consistent while it reads them.
+func (l *Loader) addHook(hook func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
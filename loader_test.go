@@ -0,0 +1,20 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+)
+
+// Load structs with a Loader for args, the environment env and opts. Returns
+// the Loader, the output of its flags (print the help to add it) and the
+// error from Load.
+func testLoad(args []string, env map[string]string, opts []Option,
+	structs ...interface{}) (*Loader, *bytes.Buffer, error) {
+
+	out := &bytes.Buffer{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(out)
+	opts = append([]Option{WithFlagSet(fs, args), WithEnvMap(env)}, opts...)
+	l := NewLoader(opts...)
+	return l, out, l.Load(structs...)
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Publish the effective configuration, with env_secret fields redacted, as the
+// expvar name once loaded. Like expvar.Publish it panics if name is in use.
+func WithExpvar(name string) Option {
+	return func(l *Loader) {
+		l.expvar = name
+	}
+}
+
+// Publish the fields as the expvar l.expvar, if set.
+func (l *Loader) publish() {
+	if l.expvar == "" || l.published {
+		return
+	}
+	l.published = true
+
+	expvar.Publish(l.expvar, expvar.Func(func() interface{} {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		m := make(map[string]interface{})
+		for _, f := range l.fields {
+			names := strings.Split(f.name, ".")
+			group := m
+			for _, name := range append([]string{f.strct},
+				names[:len(names)-1]...) {
+
+				sub, ok := group[name].(map[string]interface{})
+				if !ok {
+					sub = make(map[string]interface{})
+					group[name] = sub
+				}
+				group = sub
+			}
+			group[names[len(names)-1]] = f.exported()
+		}
+		return m
+	}))
+}
+
+// The field's value for JSON: numbers, strings and bools as they are, other
+// types in the form their Value parses.
+func (f *field) exported() interface{} {
+	if f.secret {
+		return redacted
+	}
+
+	switch f.value.(type) {
+	case *StringValue, *IntValue, *Int64Value, *Uint64Value, *BoolValue,
+		*Float64Value:
+		v := f.value.get()
+		if _, err := json.Marshal(v); err == nil {
+			return v
+		}
+	}
+	return f.current()
+}
+
+// An attribute logging the fields of the struct strct points to as a group
+// named after its type, e.g.
+//
+//	slog.Info("loaded", config.Log(&cfg))
+//
+// Nested structs are nested groups. env_secret fields are redacted and env_no
+// fields left out. Values are in the form they are published as an expvar, so
+// durations are 1m30s rather than nanoseconds. Anything other than a struct is
+// logged as it is, as "config". Fields a Loader set from encrypted values are
+// redacted too.
+func Log(strct interface{}) slog.Attr {
+	if reflect.ValueOf(strct).Kind() != reflect.Ptr {
+		return logAttr(strct, nil)
+	}
+	return logAttr(strct, decryptedFields(strct))
+}
+
+// Log strct as the package's Log does, redacting the fields of strct the Loader
+// set from encrypted values.
+func (l *Loader) Log(strct interface{}) slog.Attr {
+	l.mu.Lock()
+	decrypted := make(map[string]bool)
+	for _, f := range l.fields {
+		if f.parent == strct && f.secret {
+			decrypted[f.name] = true
+		}
+	}
+	l.mu.Unlock()
+	return logAttr(strct, decrypted)
+}
+
+// Log strct, redacting the dotted names in secret as well as env_secret fields.
+func logAttr(strct interface{}, secret map[string]bool) slog.Attr {
+	t := reflect.Indirect(reflect.ValueOf(strct))
+	if t.Kind() != reflect.Struct {
+		return slog.Any("config", strct)
+	}
+	return slog.Any(t.Type().Name(), structValuer{t, secret})
+}
+
+// Logs a struct's fields when the record is handled.
+type structValuer struct {
+	t      reflect.Value
+	secret map[string]bool
+}
+
+func (s structValuer) LogValue() slog.Value {
+	return logStruct(s.t, s.secret, "")
+}
+
+// The fields of t, which is at the dotted prefix within the struct logged.
+func logStruct(t reflect.Value, secret map[string]bool,
+	prefix string) slog.Value {
+
+	typeOfT := t.Type()
+
+	var attrs []slog.Attr
+	for j := 0; j < t.NumField(); j++ {
+		sf := typeOfT.Field(j)
+		if _, ok := sf.Tag.Lookup("env_no"); ok || sf.PkgPath != "" {
+			continue
+		}
+		f := t.Field(j)
+		name := prefix + sf.Name
+
+		switch {
+		case isNested(f.Type()):
+			attrs = append(attrs, slog.Attr{Key: sf.Name,
+				Value: logStruct(f, secret, name+".")})
+		case isStructSlice(f.Type()):
+			var elems []slog.Attr
+			for i := 0; i < f.Len(); i++ {
+				elems = append(elems, slog.Attr{Key: strconv.Itoa(i),
+					Value: logStruct(f.Index(i), secret,
+						fmt.Sprintf("%s[%d].", name, i))})
+			}
+			attrs = append(attrs, slog.Attr{Key: sf.Name,
+				Value: slog.GroupValue(elems...)})
+		case isStructMap(f.Type()):
+			keys := f.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return keys[i].String() < keys[j].String()
+			})
+			var elems []slog.Attr
+			for _, k := range keys {
+				v := reflect.Indirect(f.MapIndex(k))
+				if !v.IsValid() {
+					continue
+				}
+				elems = append(elems, slog.Attr{Key: k.String(),
+					Value: logStruct(v, secret,
+						fmt.Sprintf("%s[%s].", name, k.String()))})
+			}
+			attrs = append(attrs, slog.Attr{Key: sf.Name,
+				Value: slog.GroupValue(elems...)})
+		case hasTag(sf.Tag, "env_secret") || secret[name]:
+			attrs = append(attrs, slog.String(sf.Name, redacted))
+		default:
+			attrs = append(attrs, slog.Attr{Key: sf.Name,
+				Value: logValue(f, sf.Tag)})
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// The value of field f as it is published as an expvar (see field.current):
+// the types other than numbers, strings and bools in the form they are parsed.
+func logValue(f reflect.Value, tag reflect.StructTag) slog.Value {
+	switch f.Type() {
+	case durationType:
+		return slog.StringValue(FormatDuration(time.Duration(f.Int())))
+	case byteSizeType:
+		return slog.StringValue(ByteSize(f.Uint()).String())
+	case weekdayType:
+		return slog.StringValue(time.Weekday(f.Int()).String())
+	case timeType:
+		layout := tag.Get("env_layout")
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return slog.StringValue(f.Interface().(time.Time).Format(layout))
+	case locationType:
+		loc, _ := f.Interface().(*time.Location)
+		if loc == nil {
+			return slog.StringValue("")
+		}
+		return slog.StringValue(loc.String())
+	case intType, int64Type:
+		if tag.Get("env_unit") == "bytes" {
+			return slog.StringValue(ByteSize(f.Int()).String())
+		}
+	case uint64Type:
+		if tag.Get("env_unit") == "bytes" {
+			return slog.StringValue(ByteSize(f.Uint()).String())
+		}
+	}
+	return slog.AnyValue(f.Interface())
+}
+
+func hasTag(tag reflect.StructTag, key string) bool {
+	_, ok := tag.Lookup(key)
+	return ok
+}
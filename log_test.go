@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type logDB struct {
+	Host     string `env_def:"localhost"`
+	Password string `env_def:"hunter2" env_secret:""`
+}
+
+type logTest struct {
+	Port    int           `env_name:"port" env_def:"80"`
+	Timeout time.Duration `env_name:"timeout" env_def:"90s"`
+	DB      logDB
+	Zone    *time.Location `env_name:"zone" env_def:"UTC"`
+	Cache   int            `env_no:""`
+}
+
+func TestLog(t *testing.T) {
+	cfg := logTest{Port: 80, Timeout: time.Minute,
+		DB: logDB{Host: "db", Password: "hunter2"}, Zone: time.UTC}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", Log(&cfg))
+
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Error("Secret should be redacted", buf.String())
+	}
+
+	var rec struct {
+		LogTest struct {
+			Port    int
+			Timeout string
+			DB      struct{ Host, Password string }
+			Zone    string
+			Cache   *int
+		} `json:"logTest"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	c := rec.LogTest
+	if c.Port != 80 || c.Timeout != "1m" || c.DB.Host != "db" ||
+		c.DB.Password != redacted || c.Zone != "UTC" || c.Cache != nil {
+		t.Error("Unexpected record", buf.String())
+	}
+}
+
+func TestLogNotStruct(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("loaded", Log(nil), Log((*logTest)(nil)), Log(42))
+	if !strings.Contains(buf.String(), `"config":42`) {
+		t.Error("Unexpected record", buf.String())
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	var cfg logTest
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithEnvMap(nil),
+		WithExpvar("config_test"))
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get("config_test").String()),
+		&doc); err != nil {
+		t.Fatal(err)
+	}
+	c := doc["logTest"]
+	db, _ := c["DB"].(map[string]interface{})
+	if c["Port"] != 80.0 || c["Timeout"] != "1m30s" || c["Zone"] != "UTC" ||
+		db["Host"] != "localhost" || db["Password"] != redacted {
+		t.Error("Unexpected expvar", doc)
+	}
+	if _, ok := c["Cache"]; ok {
+		t.Error("env_no fields should not be published")
+	}
+}
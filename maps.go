@@ -0,0 +1,171 @@
+/*
+Maps of structs are configured by name. A field
+
+	Backends map[string]Backend `env_name:"BACKEND"`
+
+has a value for each name given for it in the environment
+(BACKEND_PRIMARY_URL, BACKEND_ARCHIVE_URL...), on the command line
+(-BACKEND_PRIMARY_URL or the lower case dotted -backend.primary.url) or by a
+file (an object of objects, "BACKEND": {"primary": {"URL": ...}}). The name is
+what comes between the map's name and the name of one of the value type's
+fields, the shortest if several fields match, and is used as given: PRIMARY and
+primary are different values. A dotted name, being lower case, is of the value
+whose name matches it ignoring case if there is one, and values whose names
+differ only in case have no dotted names. The fields of each value are
+configured from the value type's tags, and its Validate method is called with
+the name in the error. The values can be structs or pointers to structs; the
+names are fixed when the struct is loaded.
+*/
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Whether t is a map of (pointers to) structs by string configured by name.
+func isStructMap(t reflect.Type) bool {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return isNested(elem)
+}
+
+// Register the fields of a value of the map field f for each name given for
+// it, keeping the values already in the map (set by a Defaults method).
+func (l *Loader) registerMap(m map[string]ConfigFlag, s interface{},
+	strct string, pf planField, f reflect.Value, defaults bool) []error {
+
+	elemType := f.Type().Elem()
+	ptr := elemType.Kind() == reflect.Ptr
+	if ptr {
+		elemType = elemType.Elem()
+	}
+
+	keys, err := l.givenKeys()
+	if err != nil {
+		return []error{&FieldError{Struct: strct, Field: pf.name, Name: pf.key,
+			Err: err}}
+	}
+	if f.IsNil() {
+		f.Set(reflect.MakeMap(f.Type()))
+	}
+	var names []string
+	for _, k := range f.MapKeys() {
+		names = append(names, k.String())
+	}
+	names = mapNames(names, keys, pf.key, pf.path, planFor(elemType))
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		k := reflect.ValueOf(name).Convert(f.Type().Key())
+		v := reflect.New(elemType)
+		if cur := f.MapIndex(k); !cur.IsValid() {
+			callDefaults(v.Interface())
+		} else if !ptr {
+			v.Elem().Set(cur)
+		} else if !cur.IsNil() {
+			v = cur
+		}
+		if ptr {
+			f.SetMapIndex(k, v)
+		}
+
+		fieldName := fmt.Sprintf("%s[%s]", pf.name, name)
+		path := append(append([]string(nil), pf.path...), name)
+		p := &plan{element: true, noDotted: foldedDup(names, name)}
+		p.add(elemType, nil, fieldName, path)
+		p.addConstraints()
+
+		elem := v.Elem()
+		errs = append(errs, l.register(m, s, strct, p,
+			func(j int) interface{} {
+				return elem.FieldByIndex(p.fields[j].index).Addr().Interface()
+			}, defaults || isDefaults(v.Interface()))...)
+
+		e := element{s: v.Interface(), name: strct + "." + fieldName}
+		if !ptr {
+			e.store = func() { f.SetMapIndex(k, elem) }
+		}
+		l.elements = append(l.elements, e)
+	}
+	return errs
+}
+
+// Add to names those of the values of the map field key (at path) in the
+// given keys, whose fields are those of p. Dotted names are only added if no
+// other name matches them ignoring case.
+func mapNames(names, keys []string, key string, path []string,
+	p *plan) []string {
+
+	var dottedNames []string
+	for _, k := range keys {
+		if name, ok := mapName(k, key+"_", "_", p, false); ok {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		} else if name, ok := mapName(k, dotted(path)+".", ".", p, true); ok {
+			dottedNames = append(dottedNames, name)
+		}
+	}
+
+	for _, name := range dottedNames {
+		if !containsFold(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Whether a contains s ignoring case.
+func containsFold(a []string, s string) bool {
+	for _, x := range a {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether another of names is name ignoring case, so they would share a
+// dotted name.
+func foldedDup(names []string, name string) bool {
+	for _, x := range names {
+		if x != name && strings.EqualFold(x, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// The name in k between prefix and the key of one of p's fields (its dotted
+// name if isDotted), the shortest if several match.
+func mapName(k, prefix, sep string, p *plan, isDotted bool) (string, bool) {
+	rest, ok := strings.CutPrefix(k, prefix)
+	if !ok {
+		return "", false
+	}
+
+	name := ""
+	for _, pf := range p.fields {
+		if pf.err != nil {
+			continue
+		}
+		fk := pf.key
+		if isDotted {
+			fk = dotted(pf.path)
+		}
+		n, ok := strings.CutSuffix(rest, sep+fk)
+		if ok && n != "" && (name == "" || len(n) < len(name)) {
+			name = n
+		}
+	}
+	return name, name != ""
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mapTLS struct {
+	Cert string `env_name:"CERT"`
+}
+
+type mapBackend struct {
+	URL       string        `env_name:"URL"`
+	BackupURL string        `env_name:"BACKUP_URL"`
+	Timeout   time.Duration `env_name:"TIMEOUT" env_def:"5s"`
+	Password  string        `env_name:"PASSWORD" env_secret:""`
+	TLS       mapTLS        `env_name:"TLS"`
+}
+
+func (b *mapBackend) Validate() error {
+	if b.URL == "" {
+		return errors.New("URL is required")
+	}
+	return nil
+}
+
+type mapTest struct {
+	Backends map[string]mapBackend `env_name:"BACKEND"`
+}
+
+type mapPtrTest struct {
+	Backends map[string]*mapBackend `env_name:"BACKEND"`
+}
+
+func (m *mapPtrTest) Defaults() {
+	m.Backends = map[string]*mapBackend{"local": {URL: "http://localhost"}}
+}
+
+func TestMapEnv(t *testing.T) {
+	var cfg mapTest
+	_, _, err := testLoad(nil, map[string]string{
+		"BACKEND_PRIMARY_URL":      "http://a",
+		"BACKEND_PRIMARY_PASSWORD": "hunter2",
+		"BACKEND_ARCHIVE_URL":      "http://b",
+		"BACKEND_ARCHIVE_TLS_CERT": "b.pem",
+		"BACKEND_OLD_BACKUP_URL":   "http://c",
+		"BACKEND_OLD_URL":          "http://d",
+	}, nil, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := cfg.Backends
+	if len(b) != 3 || b["PRIMARY"].URL != "http://a" ||
+		b["PRIMARY"].Timeout != 5*time.Second || b["ARCHIVE"].TLS.Cert != "b.pem" ||
+		b["OLD"].BackupURL != "http://c" || b["OLD"].URL != "http://d" {
+		t.Error("Unexpected backends", b)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", Log(&cfg))
+	if strings.Contains(buf.String(), "hunter2") ||
+		!strings.Contains(buf.String(), `"ARCHIVE":{"URL":"http://b"`) {
+		t.Error("Unexpected log", buf.String())
+	}
+}
+
+func TestMapFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	doc := `{"BACKEND": {"primary": {"URL": "http://a", "TIMEOUT": "1m"}}}`
+	if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg mapPtrTest
+	_, _, err := testLoad([]string{"-backend.primary.tls.cert", "a.pem"}, nil,
+		[]Option{WithSources(FlagSource(), EnvSource(nil), FileSource(path),
+			DefaultSource())}, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := cfg.Backends
+	if len(b) != 2 || b["primary"].URL != "http://a" ||
+		b["primary"].Timeout != time.Minute || b["primary"].TLS.Cert != "a.pem" ||
+		b["local"].URL != "http://localhost" || b["local"].Timeout != 5*time.Second {
+		t.Error("Unexpected backends", b)
+	}
+}
+
+func TestMapValidate(t *testing.T) {
+	var cfg mapTest
+	_, _, err := testLoad(nil, map[string]string{
+		"BACKEND_PRIMARY_URL": "http://a", "BACKEND_ARCHIVE_TIMEOUT": "1s"},
+		nil, &cfg)
+
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Struct != "mapTest.Backends[ARCHIVE]" {
+		t.Error("Expected the value's validation error", err)
+	}
+
+	_, _, err = testLoad(nil, map[string]string{
+		"BACKEND_PRIMARY_URL": "http://a", "BACKEND_PRIMARY_TIMEOUT": "soon"},
+		nil, &mapTest{})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "Backends[PRIMARY].Timeout" ||
+		fe.Name != "BACKEND_PRIMARY_TIMEOUT" {
+		t.Error("Expected the value's field error", err)
+	}
+}
+
+func TestMapDottedCase(t *testing.T) {
+	var cfg mapTest
+	_, _, err := testLoad([]string{"-backend.primary.timeout", "1m"},
+		map[string]string{"BACKEND_PRIMARY_URL": "http://a"}, nil, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := cfg.Backends
+	if len(b) != 1 || b["PRIMARY"].URL != "http://a" ||
+		b["PRIMARY"].Timeout != time.Minute {
+		t.Error("The dotted name should set the PRIMARY value", b)
+	}
+
+	cfg = mapTest{}
+	l, _, err := testLoad(nil, map[string]string{
+		"BACKEND_PRIMARY_URL": "http://a", "BACKEND_primary_URL": "http://b"},
+		nil, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends["primary"].URL != "http://b" {
+		t.Error("Unexpected backends", cfg.Backends)
+	}
+	if l.flags.Lookup("backend.primary.url") != nil {
+		t.Error("Names differing in case should have no dotted names")
+	}
+}
+
+func TestMapReload(t *testing.T) {
+	env := map[string]string{"BACKEND_PRIMARY_URL": "http://a"}
+	var cfg mapTest
+	l, _, err := testLoad(nil, env, nil, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env["BACKEND_PRIMARY_URL"] = "http://b"
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Backends["PRIMARY"].URL != "http://b" {
+		t.Error("Reload should update the value", cfg.Backends)
+	}
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The configuration fields of a struct type, nested structs flattened, with
+// their names and tags worked out once so loading the type again only has to
+// create its flags and Values.
+type plan struct {
+	fields      []planField
+	constraints []constraint
+	tagErrs     []*TagError // in constraints, Struct is not filled in
+	element     bool        // the fields of an element of a slice field
+	noDotted    bool        // the element's fields have no dotted names
+}
+
+type planField struct {
+	index  []int     // for reflect.Value.FieldByIndex
+	name   string    // name of the struct field, dotted if nested
+	path   []string  // names of the field and the structs it is in
+	key    string    // flag/env name
+	tag    fieldTags // for the tags looked up when loading
+	err    error     // the field can not be configured
+	def    string    // env_def
+	isDef  bool
+	desc   string // env_desc or the key, noting if it is deprecated
+	secret bool
+
+	// def parsed for the field's type, if parsed is set (it is not in a Plan
+	// or if def does not parse, when the error is reported on loading)
+	defValue interface{}
+	parsed   bool
+
+	vt           valueTags
+	envDesc      string // env_desc
+	deprecated   string // env_deprecated
+	isDeprecated bool
+	mutable      bool   // env_mutable
+	defFunc      string // env_def_func
+	isDefFunc    bool
+}
+
+// Plans by reflect.Type, shared by all Loaders.
+var plans sync.Map
+
+// The plan for struct type t, made on first use.
+func planFor(t reflect.Type) *plan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*plan)
+	}
+
+	p := &plan{}
+	p.add(t, nil, "", nil)
+	p.addConstraints()
+	// Another goroutine may have made it too, either is fine
+	actual, _ := plans.LoadOrStore(t, p)
+	return actual.(*plan)
+}
+
+// Add the fields of struct t, which is at index, goPath and path within the
+// struct being planned.
+func (p *plan) add(t reflect.Type, index []int, goPath string, path []string) {
+	for j := 0; j < t.NumField(); j++ {
+		sf := t.Field(j)
+
+		tag := sf.Tag
+		if _, ok := tag.Lookup("env_no"); ok {
+			continue
+		} // Not a configuration field
+
+		name, ok := tag.Lookup("env_name")
+		if !ok {
+			name = sf.Name
+		}
+
+		fieldIndex := append(append([]int(nil), index...), j)
+		fieldPath := append(append([]string(nil), path...), name)
+		fieldGoPath := sf.Name
+		if goPath != "" {
+			fieldGoPath = goPath + "." + fieldGoPath
+		}
+
+		// Nested names are prefixed by their parents
+		key := strings.Join(fieldPath, "_")
+
+		// The exported fields of embedded unexported structs can be set
+		anonymous := sf.Anonymous && isNested(sf.Type)
+		if sf.PkgPath != "" && !anonymous {
+			p.fields = append(p.fields, unexportedPlanField(fieldGoPath, key))
+			continue
+		}
+
+		if isNested(sf.Type) {
+			p.add(sf.Type, fieldIndex, fieldGoPath, fieldPath)
+			continue
+		}
+
+		pf := newPlanField(fieldGoPath, fieldPath, tag)
+		pf.index = fieldIndex
+		pf.parseDef(reflect.New(sf.Type).Interface())
+		p.fields = append(p.fields, pf)
+	}
+}
+
+// The plan of a configuration field with tag.
+func newPlanField(name string, path []string, tag fieldTags) planField {
+
+	// Nested names are prefixed by their parents
+	key := strings.Join(path, "_")
+
+	def, isDef := tag.Lookup("env_def")
+
+	desc, ok := tag.Lookup("env_desc")
+	if !ok {
+		desc = key
+	}
+	deprecated, isDeprecated := tag.Lookup("env_deprecated")
+	if isDeprecated && tag.Get("env_alias") == "" {
+		desc += " (deprecated: " + deprecated + ")"
+	}
+
+	_, secret := tag.Lookup("env_secret")
+	_, mutable := tag.Lookup("env_mutable")
+	defFunc, isDefFunc := tag.Lookup("env_def_func")
+
+	return planField{
+		name:   name,
+		path:   path,
+		key:    key,
+		tag:    tag,
+		def:    def,
+		isDef:  isDef,
+		desc:   desc,
+		secret: secret,
+
+		vt:           parseValueTags(tag),
+		envDesc:      tag.Get("env_desc"),
+		deprecated:   deprecated,
+		isDeprecated: isDeprecated,
+		mutable:      mutable,
+		defFunc:      defFunc,
+		isDefFunc:    isDefFunc,
+	}
+}
+
+// Parse the field's default for the field p points to, unless it is encrypted
+// when it is only parsed once decrypted.
+func (pf *planField) parseDef(p interface{}) {
+	if !pf.isDef || IsEncrypted(pf.def) {
+		return
+	}
+	v, err := parseDefVal(p, pf.vt, pf.def)
+	pf.defValue, pf.parsed = v, err == nil
+}
+
+// The tags of a field, a reflect.StructTag or, in a Plan, a tagMap.
+type fieldTags interface {
+	Get(key string) string
+	Lookup(key string) (string, bool)
+}
+
+// Tags by name, as given by the code configgen generates.
+type tagMap map[string]string
+
+func (m tagMap) Get(key string) string {
+	return m[key]
+}
+
+func (m tagMap) Lookup(key string) (string, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+func unexportedPlanField(name, key string) planField {
+	return planField{name: name, key: key,
+		err: fmt.Errorf("Unexported field, tag it env_no to skip it")}
+}
+
+// A field of a struct type as described by the code configgen generates.
+type PlanField struct {
+	Name       string            // name of the struct field, dotted if nested
+	Path       []string          // env_name (or name) of the field and its parents
+	Tags       map[string]string // the field's env_ tags by name
+	Unexported bool              // the field can not be set, which is an error
+
+	// The env_def tag parsed for the field's type, nil if it has none or it
+	// is parsed when loading.
+	Default interface{}
+}
+
+// A Plan describes the configuration fields of a struct type so a Loader can
+// load it without reflecting on the type or its tags. Plans are made by the
+// code configgen generates.
+type Plan struct {
+	name string
+	p    *plan
+}
+
+// Create the Plan for the struct type typeName with fields, env_no fields
+// left out and nested structs flattened.
+func NewPlan(typeName string, fields []PlanField) *Plan {
+	p := &plan{}
+	for _, f := range fields {
+		if f.Unexported {
+			p.fields = append(p.fields,
+				unexportedPlanField(f.Name, strings.Join(f.Path, "_")))
+			continue
+		}
+		pf := newPlanField(f.Name, f.Path, tagMap(f.Tags))
+		if f.Default != nil {
+			pf.defValue, pf.parsed = f.Default, true
+		}
+		p.fields = append(p.fields, pf)
+	}
+	p.addConstraints()
+	return &Plan{typeName, p}
+}
+
+// Load strct, a pointer to a struct of p's type, as Load does. ptrs are
+// pointers to its fields, in the order of p's fields with nil for unexported
+// fields; ptrs is nil if strct is. The fields are set through the pointers,
+// only slices and maps of structs (and unsupported types, to report them) are
+// reflected on.
+func (l *Loader) LoadPlan(strct interface{}, p *Plan, ptrs []interface{}) error {
+	if ptrs == nil {
+		return fmt.Errorf("Load needs pointers to structs, not %T", strct)
+	}
+	if len(ptrs) != len(p.p.fields) {
+		return fmt.Errorf("%d field pointers for the %d fields of %s",
+			len(ptrs), len(p.p.fields), p.name)
+	}
+
+	return l.load([]target{{strct, p.name, p.p, func(i int) interface{} {
+		return ptrs[i]
+	}}})
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type planDB struct {
+	Host     string        `env_name:"host" env_def:"localhost" env_desc:"Database host"`
+	Port     int           `env_name:"port" env_def:"5432"`
+	Timeout  time.Duration `env_name:"timeout" env_def:"30s"`
+	Password string        `env_name:"password" env_secret:""`
+}
+
+type planTest struct {
+	Name    string   `env_name:"name" env_def:"app"`
+	Workers int      `env_name:"workers" env_def:"4" env_short:"w"`
+	Debug   bool     `env_name:"debug"`
+	Ratio   float64  `env_name:"ratio" env_def:"0.5"`
+	Cache   ByteSize `env_name:"cache" env_def:"64MiB"`
+	DB      planDB   `env_name:"db"`
+	Replica planDB   `env_name:"replica"`
+	Ignored string   `env_no:""`
+}
+
+func TestPlanCached(t *testing.T) {
+	typ := reflect.TypeOf(planTest{})
+	p := planFor(typ)
+	if planFor(typ) != p {
+		t.Error("The plan should be cached")
+	}
+	if len(p.fields) != 13 {
+		t.Error("Unexpected number of fields", len(p.fields))
+	}
+	if f := p.fields[5]; f.key != "db_host" || f.name != "DB.Host" ||
+		f.def != "localhost" || f.desc != "Database host" {
+		t.Error("Unexpected field", f)
+	}
+	if f := p.fields[1]; !f.parsed || f.defValue != 4 {
+		t.Error("The default should be parsed in the plan", f.defValue)
+	}
+}
+
+func TestPlanConcurrent(t *testing.T) {
+	plans.Delete(reflect.TypeOf(planTest{}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var cfg planTest
+			_, _, err := testLoad(nil, map[string]string{"db_port": "6543"},
+				nil, &cfg)
+			if err != nil || cfg.DB.Port != 6543 || cfg.Replica.Port != 5432 {
+				t.Error("Unexpected config", cfg, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkLoad(b *testing.B) {
+	env := map[string]string{"workers": "8", "db_host": "db"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := testLoad(nil, env, nil, &planTest{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Loading without the cached plan, as every load did before plans.
+func BenchmarkLoadUncached(b *testing.B) {
+	env := map[string]string{"workers": "8", "db_host": "db"}
+	typ := reflect.TypeOf(planTest{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		plans.Delete(typ)
+		if _, _, err := testLoad(nil, env, nil, &planTest{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Register and set the fields of the struct t, nested structs flattened, the
+// way Load did before plans: reading each field's tags and parsing its default
+// with parseDefault every time.
+func parseDefaultWalk(fs *flag.FlagSet, m map[string]ConfigFlag,
+	t reflect.Value, prefix string) error {
+
+	typeOfT := t.Type()
+	for j := 0; j < t.NumField(); j++ {
+		f := t.Field(j)
+		tag := typeOfT.Field(j).Tag
+		if _, ok := tag.Lookup("env_no"); ok {
+			continue
+		}
+
+		name, ok := tag.Lookup("env_name")
+		if !ok {
+			name = typeOfT.Field(j).Name
+		}
+		name = prefix + name
+		if isNested(f.Type()) {
+			if err := parseDefaultWalk(fs, m, f, name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		defVal, isDefVal := tag.Lookup("env_def")
+		desc, ok := tag.Lookup("env_desc")
+		if !ok {
+			desc = name
+		}
+		v, err := registerFlag(fs, m, f, tag, name, defVal, desc, isDefVal)
+		if err != nil {
+			return err
+		}
+		if err := v.Set(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The parseDefault walk Load made before plans, without sources, aliases or
+// constraints, as a baseline for BenchmarkLoad.
+func BenchmarkParseDefaultWalk(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var cfg planTest
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		err := parseDefaultWalk(fs, make(map[string]ConfigFlag),
+			reflect.ValueOf(&cfg).Elem(), "")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
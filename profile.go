@@ -0,0 +1,201 @@
+/*
+Profiles select a set of overrides for an environment (dev, staging, prod).
+The profile is taken from WithProfile, else the -profile flag, else the
+APP_PROFILE environmental var. With a profile fields use their env_def_<profile>
+tag as the default when they have one and ProfileFiles layers the profile's
+file over the base file.
+*/
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Use profile regardless of the command line and environment.
+func WithProfile(profile string) Option {
+	return func(l *Loader) {
+		l.profile = profile
+	}
+}
+
+// Read the profile from the environmental var name instead of APP_PROFILE.
+func WithProfileEnv(name string) Option {
+	return func(l *Loader) {
+		l.profileEnv = name
+	}
+}
+
+// The profile used by the last Load, "" if none.
+func (l *Loader) Profile() string {
+	return l.profile
+}
+
+// profiled is implemented by sources which depend on the profile.
+type profiled interface {
+	setProfile(profile string)
+}
+
+// Set the profile from the arguments or environment if not already given.
+// The flags have not been parsed yet as the defaults depend on the profile.
+func (l *Loader) setProfile(targets []target) {
+	if l.profile != "" {
+		return
+	}
+
+	if p, ok := scanArg(l.args, "profile", l.boolFlags(targets),
+		l.gnu); ok {
+		l.profile = p
+	} else if p, ok := l.lookupEnv(l.profileEnv); ok {
+		l.profile = p
+	}
+}
+
+// Register -profile, unless a field uses the name, so it is accepted when the
+// flags are parsed.
+func (l *Loader) registerProfile() {
+	if l.flags.Lookup("profile") == nil {
+		l.flags.String("profile", l.profile, "Configuration profile")
+	}
+}
+
+// Find the value of flag name in args (-name=v, -name v, --name=v or
+// --name v). The value after any other flag not in isBool is skipped, unless
+// it is a flag itself. The first non-flag argument ends the flags as
+// flag.Parse does, unless interspersed (GNU style).
+func scanArg(args []string, name string, isBool map[string]bool,
+	interspersed bool) (string, bool) {
+
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		if len(a) < 2 || a[0] != '-' {
+			if interspersed {
+				continue
+			}
+			break
+		}
+
+		a = strings.TrimPrefix(strings.TrimPrefix(a, "-"), "-")
+		flagName, value, hasValue := strings.Cut(a, "=")
+		if flagName == name {
+			if hasValue {
+				return value, true
+			}
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+			return "", false
+		}
+
+		if !hasValue && !isBool[flagName] && i+1 < len(args) &&
+			!strings.HasPrefix(args[i+1], "-") {
+			i++
+		}
+	}
+	return "", false
+}
+
+// The names of the bool flags, which do not take a separate value: those
+// already registered and those the fields of targets will register.
+func (l *Loader) boolFlags(targets []target) map[string]bool {
+	names := make(map[string]bool)
+	l.flags.VisitAll(func(f *flag.Flag) {
+		if isBoolFlag(f.Value) {
+			names[f.Name] = true
+		}
+	})
+
+	for _, t := range targets {
+		for i, pf := range t.p.fields {
+			if pf.err != nil {
+				continue
+			}
+			switch t.fieldOf(i).(type) {
+			case *bool:
+			case *int:
+				if !pf.vt.count {
+					continue
+				}
+			default:
+				continue
+			}
+
+			names[pf.key] = true
+			names["no-"+pf.key] = true
+			if short := pf.tag.Get("env_short"); short != "" {
+				names[short] = true
+			}
+			parent := pf.path[:len(pf.path)-1]
+			for _, a := range strings.Split(pf.tag.Get("env_alias"), ",") {
+				if a = strings.TrimSpace(a); a != "" {
+					key := append(append([]string(nil), parent...), a)
+					names[strings.Join(key, "_")] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// ProfileFiles reads path (a FileSource) with config.<profile>.json layered
+// over config.json for path config.json. The profile's file is optional, the
+// provenance of a field names the file which supplied it.
+func ProfileFiles(path string) Source {
+	return &profileFiles{base: FileSource(path), path: path}
+}
+
+type profileFiles struct {
+	base    Source
+	path    string
+	overlay Source // nil if there is no profile or no file for it
+}
+
+func (s *profileFiles) setProfile(profile string) {
+	s.overlay = nil
+	if profile == "" {
+		return
+	}
+
+	ext := filepath.Ext(s.path)
+	path := strings.TrimSuffix(s.path, ext) + "." + profile + ext
+	if _, err := os.Stat(path); err == nil {
+		s.overlay = FileSource(path)
+	}
+}
+
+func (s *profileFiles) Name() string {
+	return s.base.Name()
+}
+
+func (s *profileFiles) Lookup(key string) (string, bool, error) {
+	return s.layer(key).Lookup(key)
+}
+
+func (s *profileFiles) keys() ([]string, error) {
+	keys, err := s.base.(keyLister).keys()
+	if err != nil || s.overlay == nil {
+		return keys, err
+	}
+
+	overlay, err := s.overlay.(keyLister).keys()
+	return append(keys, overlay...), err
+}
+
+// Resolve against the layer which has the key so it is named as the source.
+func (s *profileFiles) bind(f *field, key string) Source {
+	return s.layer(key)
+}
+
+func (s *profileFiles) layer(key string) Source {
+	if s.overlay != nil {
+		if _, ok, err := s.overlay.Lookup(key); ok || err != nil {
+			return s.overlay
+		}
+	}
+	return s.base
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		gnu  bool
+		v    string
+		ok   bool
+	}{
+		{[]string{"-profile=prod"}, false, "prod", true},
+		{[]string{"-a", "--profile", "dev"}, false, "dev", true},
+		{[]string{"--profile=staging", "x"}, false, "staging", true},
+		{[]string{"x", "-profile=prod"}, false, "", false},
+		{[]string{"-profiles=prod"}, false, "", false},
+		{[]string{"-port", "80", "-profile", "prod"}, false, "prod", true},
+		{[]string{"-v", "x", "-profile", "prod"}, false, "", false},
+		{[]string{"-v", "x", "--profile", "prod"}, true, "prod", true},
+		{[]string{"-port", "profile", "x"}, false, "", false},
+	}
+
+	isBool := map[string]bool{"v": true}
+	for _, test := range tests {
+		v, ok := scanArg(test.args, "profile", isBool, test.gnu)
+		if v != test.v || ok != test.ok {
+			t.Error(test.args, "should give", test.v, test.ok, "not", v, ok)
+		}
+	}
+}
+
+type profileTest struct {
+	Host  string `env_def:"localhost" env_def_prod:"prod.local"`
+	Port  int    `env_def:"80"`
+	Debug bool   `env_def:"true" env_def_prod:"false"`
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProfileDefaults(t *testing.T) {
+	var ss profileTest
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-profile", "prod"}))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Profile() != "prod" {
+		t.Error("Profile should be prod", l.Profile())
+	}
+	if ss.Host != "prod.local" || ss.Port != 80 || ss.Debug {
+		t.Error("Should use the prod defaults", ss)
+	}
+	if p := l.Provenance(); p["profileTest.Host"] != "default prod" ||
+		p["profileTest.Port"] != "default" {
+		t.Error("Provenance should name the profile default", p)
+	}
+
+	// The values of other flags are skipped, flags after an argument are not
+	// parsed
+	for args, want := range map[string]string{
+		"-Port 90 -profile prod": "prod",
+		"-Debug x -profile prod": "",
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		l := NewLoader(WithFlagSet(fs, strings.Fields(args)))
+		if err := l.Load(&profileTest{}); err != nil {
+			t.Fatal(err)
+		}
+		if l.Profile() != want {
+			t.Errorf("%s should use profile %q, not %q", args, want, l.Profile())
+		}
+	}
+}
+
+func TestProfileFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.json")
+	writeFile(t, base, `{"Host": "base", "Port": 81}`)
+	writeFile(t, filepath.Join(dir, "config.staging.json"), `{"Host": "staging"}`)
+
+	var ss profileTest
+
+	env := map[string]string{"APP_PROFILE": "staging"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithEnv(mapLookup(env)),
+		WithSource(ProfileFiles(base), 2))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.Host != "staging" || ss.Port != 81 {
+		t.Error("Staging should be layered over the base file", ss)
+	}
+
+	p := l.Provenance()
+	if p["profileTest.Host"] != "file "+filepath.Join(dir, "config.staging.json") ||
+		p["profileTest.Port"] != "file "+base {
+		t.Error("Provenance should name the layer", p)
+	}
+}
+
+func TestProfileFilesNoOverlay(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.json")
+	writeFile(t, base, `{"Host": "base"}`)
+
+	var ss profileTest
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithProfile("dev"),
+		WithSources(ProfileFiles(base), DefaultSource()))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.Host != "base" || ss.Port != 80 {
+		t.Error("Missing profile file should use the base", ss)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Fields with the same name, in the same or different structs, share a flag and
+environment variable: one flag feeds all of them. So that the flag has a single
+meaning the fields must have the same type (and env_layout, env_unit and
+env_count), the same default (or none) and, if more than one gives an env_desc,
+the same description. An env_alias can not be the name or alias of a field
+with a different name.
+*/
+package config
+
+import (
+	"fmt"
+)
+
+// Check fld agrees with the fields already registered with its name.
+func (l *Loader) checkShared(fld *field) error {
+	for _, other := range l.fields {
+		if other.key != fld.key {
+			continue
+		}
+
+		conflict := func(format string, v ...interface{}) error {
+			return &FieldError{Struct: fld.strct, Field: fld.name,
+				Name: fld.key, Err: fmt.Errorf("Shares its name with %s.%s "+
+					"but "+format, append([]interface{}{other.strct,
+					other.name}, v...)...)}
+		}
+
+		if describeType(fld) != describeType(other) {
+			return conflict("%s is not %s", describeType(fld),
+				describeType(other))
+		}
+
+		if t, ok := fld.value.(*TimeValue); ok &&
+			t.layout != other.value.(*TimeValue).layout {
+			return conflict("layout %q is not %q", t.layout,
+				other.value.(*TimeValue).layout)
+		}
+
+		def, isDef := fld.value.defaultString()
+		otherDef, otherIsDef := other.value.defaultString()
+		if isDef != otherIsDef || def != otherDef {
+			if fld.secret || other.secret {
+				return conflict("the defaults differ")
+			}
+			return conflict("default %s is not %s", quoteDefault(def, isDef),
+				quoteDefault(otherDef, otherIsDef))
+		}
+
+		if fld.desc != "" && other.desc != "" && fld.desc != other.desc {
+			return conflict("description %q is not %q", fld.desc, other.desc)
+		}
+		return nil
+	}
+	return nil
+}
+
+// Check the aliases of fld are not the names or aliases of the fields already
+// registered with other names, or fld's name an alias of one of them.
+func (l *Loader) checkAliases(fld *field) error {
+	for _, other := range l.fields {
+		if other.key == fld.key {
+			continue
+		}
+
+		for i := -1; i < len(fld.aliases); i++ {
+			k := fld.key
+			if i >= 0 {
+				k = fld.aliases[i].key
+			}
+			if !other.hasName(k) {
+				continue
+			}
+			what := "Alias " + k
+			if k == fld.key {
+				what = "Name"
+			}
+			return &FieldError{Struct: fld.strct, Field: fld.name,
+				Name: fld.key, Err: fmt.Errorf("%s is also a name of %s.%s",
+					what, other.strct, other.name)}
+		}
+	}
+	return nil
+}
+
+// The field's type, noting when env_unit or env_count changes how it is
+// parsed.
+func describeType(f *field) string {
+	_, isSize := f.value.get().(ByteSize)
+	if _, ok := f.value.(*ByteSizeValue); ok && !isSize {
+		return f.typeName() + " (env_unit bytes)"
+	}
+	if f.vt.count {
+		return f.typeName() + " (env_count)"
+	}
+	return f.typeName()
+}
+
+// The Go type of the field.
+func (f *field) typeName() string {
+	return fmt.Sprintf("%T", f.value.get())
+}
+
+func quoteDefault(def string, isDef bool) string {
+	if !isDef {
+		return "none"
+	}
+	return fmt.Sprintf("%q", def)
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sharedA struct {
+	Port int    `env_name:"port" env_def:"80" env_desc:"Port to listen on" env_short:"p"`
+	Host string `env_name:"host"`
+}
+
+type sharedB struct {
+	Port int `env_name:"port" env_def:"80" env_short:"p"`
+}
+
+func TestSharedName(t *testing.T) {
+	var a sharedA
+	var b sharedB
+	if _, _, err := testLoad([]string{"-p", "8080"}, nil, nil, &a,
+		&b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Port != 8080 || b.Port != 8080 {
+		t.Error("The flag should set both fields", a.Port, b.Port)
+	}
+
+	a, b = sharedA{}, sharedB{}
+	if _, _, err := testLoad(nil, nil, nil, &a, &b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Port != 80 || b.Port != 80 {
+		t.Error("Both fields should have the default", a.Port, b.Port)
+	}
+}
+
+type sharedTimes struct {
+	Start time.Time `env_name:"start" env_layout:"2006-01-02"`
+	Old   int       `env_name:"old" env_alias:"legacy"`
+}
+
+func TestSharedNameLayoutAndAlias(t *testing.T) {
+	_, _, err := testLoad(nil, nil, nil, &sharedTimes{}, &struct {
+		Start time.Time `env_name:"start"`
+	}{})
+	if err == nil || !strings.Contains(err.Error(),
+		`layout "" is not "2006-01-02"`) {
+		t.Error("Expected a layout conflict", err)
+	}
+
+	_, _, err = testLoad(nil, nil, nil, &sharedTimes{}, &struct {
+		Legacy int `env_name:"legacy"`
+	}{})
+	if err == nil || !strings.Contains(err.Error(),
+		"Name is also a name of sharedTimes.Old") {
+		t.Error("Expected an alias conflict", err)
+	}
+
+	_, _, err = testLoad(nil, nil, nil, &sharedA{}, &struct {
+		Admin int `env_name:"admin" env_alias:"port"`
+	}{})
+	if err == nil || !strings.Contains(err.Error(),
+		"Alias port is also a name of sharedA.Port") {
+		t.Error("Expected an alias conflict", err)
+	}
+
+	_, _, err = testLoad(nil, nil, nil, &sharedTimes{}, &struct {
+		Old int `env_name:"old" env_alias:"legacy"`
+	}{})
+	if err != nil {
+		t.Error("Fields sharing a name can share its aliases", err)
+	}
+}
+
+func TestSharedNameCount(t *testing.T) {
+	plain := &struct {
+		V int `env_name:"v"`
+	}{}
+	count := &struct {
+		V int `env_name:"v" env_count:""`
+	}{}
+
+	for _, structs := range [][]interface{}{{plain, count}, {count, plain}} {
+		_, _, err := testLoad([]string{"-v"}, nil, nil, structs...)
+		if err == nil || !strings.Contains(err.Error(), "(env_count)") {
+			t.Error("A counter and an int should conflict", err)
+		}
+	}
+}
+
+func TestSharedNameConflicts(t *testing.T) {
+	tests := []struct {
+		strct interface{}
+		err   string
+	}{
+		{&struct {
+			Port string `env_name:"port" env_def:"80"`
+		}{}, "Shares its name with sharedA.Port but string is not int"},
+		{&struct {
+			Port int `env_name:"port" env_def:"8080"`
+		}{}, `default "8080" is not "80"`},
+		{&struct {
+			Port int `env_name:"port"`
+		}{}, `default none is not "80"`},
+		{&struct {
+			Port int `env_name:"port" env_def:"80" env_desc:"Admin port"`
+		}{}, `description "Admin port" is not "Port to listen on"`},
+		{&struct {
+			Port int `env_name:"port" env_def:"80" env_unit:"bytes"`
+		}{}, "int (env_unit bytes) is not int"},
+		{&struct {
+			Port int `env_name:"port" env_def:"80" env_count:""`
+		}{}, "int (env_count) is not int"},
+	}
+
+	for _, test := range tests {
+		_, _, err := testLoad(nil, nil, nil, &sharedA{}, test.strct)
+
+		var fe *FieldError
+		if !errors.As(err, &fe) || fe.Name != "port" ||
+			!strings.Contains(err.Error(), test.err) {
+			t.Error("Expected a conflict", test.err, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Slices of structs are configured by index. A field
+
+	Upstreams []Upstream `env_name:"UPSTREAM"`
+
+has an element for each index given for it in the environment
+(UPSTREAM_0_HOST, UPSTREAM_1_HOST...), on the command line (-UPSTREAM_0_HOST
+or the lower case dotted -upstream.0.host) or by a file (an array of objects,
+"UPSTREAM": [{"HOST": ...}]). The fields of each element are configured from
+the element type's tags, and its Validate method is called with the element's
+index in the error. The number of elements is fixed when the struct is loaded.
+*/
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The most elements a slice field can have, to catch mistyped indexes.
+const maxSliceLen = 1000
+
+// A struct in a slice or map field, validated like the structs loaded.
+type element struct {
+	s     interface{} // pointer to the element
+	name  string      // e.g. Server.Upstreams[0]
+	store func()      // copies the element into its map once set, if needed
+}
+
+// Whether t is a slice of structs configured by index.
+func isStructSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && isNested(t.Elem())
+}
+
+// The lower case dotted flag name of a field in a slice or map element, e.g.
+// upstream.0.host.
+func dotted(path []string) string {
+	return strings.ToLower(strings.Join(path, "."))
+}
+
+// Register the fields of an element of the slice field f for each index given
+// for it, adding elements (set by their Defaults method) as needed.
+func (l *Loader) registerSlice(m map[string]ConfigFlag, s interface{},
+	strct string, pf planField, f reflect.Value, defaults bool) []error {
+
+	keys, err := l.givenKeys()
+	var n int
+	if err == nil {
+		n, err = sliceLen(keys, pf.key, pf.path)
+	}
+	if err != nil {
+		return []error{&FieldError{Struct: strct, Field: pf.name, Name: pf.key,
+			Err: err}}
+	}
+
+	had := f.Len()
+	if n > had {
+		grown := reflect.MakeSlice(f.Type(), n, n)
+		reflect.Copy(grown, f)
+		f.Set(grown)
+	}
+
+	var errs []error
+	for i := 0; i < f.Len(); i++ {
+		v := f.Index(i)
+		elem := v.Addr().Interface()
+		if i >= had {
+			callDefaults(elem)
+		}
+
+		name := fmt.Sprintf("%s[%d]", pf.name, i)
+		path := append(append([]string(nil), pf.path...), strconv.Itoa(i))
+		p := &plan{element: true}
+		p.add(v.Type(), nil, name, path)
+		p.addConstraints()
+
+		errs = append(errs, l.register(m, s, strct, p,
+			func(j int) interface{} {
+				return v.FieldByIndex(p.fields[j].index).Addr().Interface()
+			}, defaults || isDefaults(elem))...)
+		l.elements = append(l.elements,
+			element{s: elem, name: strct + "." + name})
+	}
+	return errs
+}
+
+// The number of elements of the slice field key (at path): one more than the
+// highest index in the given keys.
+func sliceLen(keys []string, key string, path []string) (int, error) {
+	n := 0
+	for _, k := range keys {
+		i, ok := sliceIndex(k, key+"_", "_")
+		if !ok {
+			i, ok = sliceIndex(k, dotted(path)+".", ".")
+		}
+		if !ok {
+			continue
+		}
+		if i >= maxSliceLen {
+			return 0, fmt.Errorf("Index %d of %s is over %d", i, k, maxSliceLen-1)
+		}
+		if i >= n {
+			n = i + 1
+		}
+	}
+	return n, nil
+}
+
+// The names given in the arguments, the environment, if in the chain, and by
+// the sources which can list their keys, where slice and map fields find their
+// elements.
+func (l *Loader) givenKeys() ([]string, error) {
+	var keys []string
+	for _, a := range l.args {
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(a, "-"), "-")
+		name, _, _ = strings.Cut(name, "=")
+		keys = append(keys, name)
+	}
+
+	for _, s := range l.sources {
+		switch x := s.(type) {
+		case envSource:
+			if l.environ == nil {
+				continue
+			}
+			for _, kv := range l.environ() {
+				k, _, _ := strings.Cut(kv, "=")
+				keys = append(keys, k)
+			}
+		case keyLister:
+			listed, err := x.keys()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, listed...)
+		}
+	}
+	return keys, nil
+}
+
+// The index in k after prefix, if it is followed by sep.
+func sliceIndex(k, prefix, sep string) (int, bool) {
+	rest, ok := strings.CutPrefix(k, prefix)
+	if !ok {
+		return 0, false
+	}
+	digits, _, ok := strings.Cut(rest, sep)
+	if !ok || digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(digits)
+	if err != nil {
+		return maxSliceLen, true
+	}
+	return i, true
+}
+
+// Register the dotted name of a field in an element as an alias of its
+// name, unless the field is deprecated (so its own name stays deprecated).
+func (l *Loader) registerDotted(m map[string]ConfigFlag, p interface{},
+	fld *field) error {
+
+	key := dotted(fld.path)
+	if fld.isDeprecated || key == fld.key {
+		return nil
+	}
+
+	v, err := newValue(l.flags, m, p, fld.vt, key, nil, "Alias for -"+fld.key)
+	if err != nil {
+		return err
+	}
+	fld.aliases = append(fld.aliases, alias{key, v.(chainValue).configFlag()})
+	return nil
+}
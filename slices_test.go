@@ -0,0 +1,144 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type sliceUpstream struct {
+	Host   string `env_name:"HOST"`
+	Port   int    `env_name:"PORT" env_def:"80"`
+	Token  string `env_name:"TOKEN" env_secret:""`
+	Weight int    `env_name:"WEIGHT" env_lte:"Port"`
+}
+
+func (u *sliceUpstream) Validate() error {
+	if u.Host == "" {
+		return errors.New("HOST is required")
+	}
+	return nil
+}
+
+type sliceTest struct {
+	Name      string          `env_name:"NAME" env_def:"api"`
+	Upstreams []sliceUpstream `env_name:"UPSTREAM"`
+}
+
+func loadSlices(args []string, env map[string]string,
+	opts ...Option) (*sliceTest, *Loader, error) {
+
+	var cfg sliceTest
+	l, _, err := testLoad(args, env, opts, &cfg)
+	return &cfg, l, err
+}
+
+func TestSliceEnv(t *testing.T) {
+	cfg, l, err := loadSlices(nil, map[string]string{
+		"UPSTREAM_0_HOST": "a", "UPSTREAM_1_HOST": "b", "UPSTREAM_1_PORT": "8080",
+		"UPSTREAM_1_TOKEN": "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := cfg.Upstreams
+	if len(u) != 2 || u[0].Host != "a" || u[0].Port != 80 || u[1].Host != "b" ||
+		u[1].Port != 8080 || u[1].Token != "s3cret" || cfg.Name != "api" {
+		t.Error("Unexpected config", cfg)
+	}
+	if p := l.Provenance(); p["sliceTest.Upstreams[1].Port"] != "env" ||
+		p["sliceTest.Upstreams[0].Port"] != "default" {
+		t.Error("Unexpected provenance", p)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("loaded", Log(cfg))
+	if strings.Contains(buf.String(), "s3cret") ||
+		!strings.Contains(buf.String(), `"1":{"Host":"b","Port":8080`) {
+		t.Error("Unexpected log", buf.String())
+	}
+}
+
+func TestSliceFlags(t *testing.T) {
+	cfg, _, err := loadSlices([]string{"-upstream.0.host=a", "-UPSTREAM_1_HOST",
+		"b", "--upstream.1.port", "8080"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := cfg.Upstreams
+	if len(u) != 2 || u[0].Host != "a" || u[1].Host != "b" || u[1].Port != 8080 {
+		t.Error("Unexpected config", cfg)
+	}
+}
+
+func TestSliceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	doc := `{"NAME": "web", "UPSTREAM": [{"HOST": "a"}, {"HOST": "b", "PORT": 8080}]}`
+	if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The environment overrides the file element by element
+	cfg, _, err := loadSlices(nil, map[string]string{"UPSTREAM_0_PORT": "81"},
+		WithSources(FlagSource(), EnvSource(nil), FileSource(path),
+			DefaultSource()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := cfg.Upstreams
+	if len(u) != 2 || u[0].Host != "a" || u[0].Port != 81 || u[1].Host != "b" ||
+		u[1].Port != 8080 || cfg.Name != "web" {
+		t.Error("Unexpected config", cfg)
+	}
+}
+
+func TestSliceErrors(t *testing.T) {
+	_, _, err := loadSlices(nil, map[string]string{
+		"UPSTREAM_0_HOST": "a", "UPSTREAM_1_PORT": "8080"})
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Struct != "sliceTest.Upstreams[1]" {
+		t.Error("Expected the element's validation error", err)
+	}
+
+	_, _, err = loadSlices(nil, map[string]string{
+		"UPSTREAM_0_HOST": "a", "UPSTREAM_0_PORT": "eighty"})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "Upstreams[0].Port" ||
+		fe.Name != "UPSTREAM_0_PORT" {
+		t.Error("Expected the element's field error", err)
+	}
+
+	_, _, err = loadSlices(nil, map[string]string{
+		"UPSTREAM_0_HOST": "a", "UPSTREAM_0_WEIGHT": "100"})
+	var ce *ConstraintError
+	if !errors.As(err, &ce) || ce.Field != "Upstreams[0].Weight" {
+		t.Error("Expected the element's constraint error", err)
+	}
+
+	_, _, err = loadSlices(nil, map[string]string{"UPSTREAM_5000_HOST": "a"})
+	if err == nil || !strings.Contains(err.Error(), "UPSTREAM_5000_HOST") {
+		t.Error("A large index should fail", err)
+	}
+}
+
+func TestSliceReload(t *testing.T) {
+	values := map[string]string{"UPSTREAM_0_HOST": "a"}
+	cfg, l, err := loadSlices(nil, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values["UPSTREAM_0_HOST"] = "b"
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Upstreams) != 1 || cfg.Upstreams[0].Host != "b" {
+		t.Error("Reload should update the element", cfg.Upstreams)
+	}
+}
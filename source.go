@@ -0,0 +1,323 @@
+/*
+Sources supply the string values used to initialize fields. A Loader consults
+its Sources in order for each field and the first one which has a value for the
+field's name wins.
+*/
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Source of configuration values keyed by the flag/env name of a field.
+type Source interface {
+	// Name of the source, used when reporting where a value came from.
+	Name() string
+	// Returns the value for key and whether the source has one.
+	Lookup(key string) (string, bool, error)
+}
+
+// A Watcher is a Source which can tell the Loader its values have changed.
+type Watcher interface {
+	Source
+	// Blocks until ctx is done, calling changed with the keys whose values
+	// have changed (nil if unknown).
+	Watch(ctx context.Context, changed func(keys []string))
+}
+
+// binder is implemented by the built-in sources whose values depend on the
+// field being resolved rather than on its name alone. key is the field's name
+// or one of its aliases.
+type binder interface {
+	bind(f *field, key string) Source
+}
+
+// The chain used by Values which are set outside of a Loader.
+var standardSources = []Source{flagSource{}, envSource{os.LookupEnv},
+	defaultSource{}}
+
+// The value given for the field on the command line.
+func FlagSource() Source {
+	return flagSource{}
+}
+
+// Values from lookup. If nil a Loader uses its environment (see WithEnv),
+// otherwise os.LookupEnv. Tests can use a map lookup instead of the process
+// environment.
+func EnvSource(lookup func(key string) (string, bool)) Source {
+	return envSource{lookup}
+}
+
+// The env_def default of the field.
+func DefaultSource() Source {
+	return defaultSource{}
+}
+
+type flagSource struct{}
+
+func (s flagSource) Name() string {
+	return "flag"
+}
+
+func (s flagSource) Lookup(key string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (s flagSource) bind(f *field, key string) Source {
+	if key != f.key {
+		return boundFlag{f.aliasFlag(key)}
+	}
+	return boundFlag{f.value.configFlag()}
+}
+
+type boundFlag struct {
+	flag ConfigFlag
+}
+
+func (s boundFlag) Name() string {
+	return "flag"
+}
+
+func (s boundFlag) Lookup(key string) (string, bool, error) {
+	if s.flag == nil || !s.flag.IsSet() {
+		return "", false, nil
+	}
+	// In full, a time's layout may drop part of it
+	if t, ok := s.flag.Get().(time.Time); ok {
+		return t.Format(time.RFC3339Nano), true, nil
+	}
+	return s.flag.String(), true, nil
+}
+
+type envSource struct {
+	lookup func(key string) (string, bool)
+}
+
+func (s envSource) Name() string {
+	return "env"
+}
+
+func (s envSource) Lookup(key string) (string, bool, error) {
+	lookup := s.lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	v, ok := lookup(key)
+	return v, ok, nil
+}
+
+type defaultSource struct{}
+
+func (s defaultSource) Name() string {
+	return "default"
+}
+
+func (s defaultSource) Lookup(key string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (s defaultSource) bind(f *field, key string) Source {
+	if key != f.key {
+		return mapSource{"default", nil}
+	}
+
+	name := f.defSource
+	if name == "" {
+		name = "default"
+	}
+	if f.defFunc != nil {
+		return boundDefaultFunc{name, f.defFunc}
+	}
+	return boundDefault{name, f.value}
+}
+
+type boundDefault struct {
+	name  string
+	value chainValue
+}
+
+func (s boundDefault) Name() string {
+	return s.name
+}
+
+func (s boundDefault) Lookup(key string) (string, bool, error) {
+	v, ok := s.value.defaultString()
+	return v, ok, nil
+}
+
+// Computes the default of a field tagged env_def_func when it is looked up.
+type boundDefaultFunc struct {
+	name string
+	fn   func() (string, error)
+}
+
+func (s boundDefaultFunc) Name() string {
+	return s.name
+}
+
+func (s boundDefaultFunc) Lookup(key string) (string, bool, error) {
+	v, err := s.fn()
+	return v, err == nil, err
+}
+
+// Values from a map, name identifies the source.
+func MapSource(name string, values map[string]string) Source {
+	return mapSource{name, values}
+}
+
+type mapSource struct {
+	name   string
+	values map[string]string
+}
+
+func (s mapSource) Name() string {
+	return s.name
+}
+
+func (s mapSource) Lookup(key string) (string, bool, error) {
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+// Values from a JSON object in the file at path, nested objects and arrays
+// flattened into keys such as DB_Host and UPSTREAM_0_HOST. The file is read on
+// the first Lookup.
+func FileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+type fileSource struct {
+	path string
+
+	once   sync.Once
+	values map[string]string
+	err    error
+}
+
+func (s *fileSource) Name() string {
+	return "file " + s.path
+}
+
+func (s *fileSource) Lookup(key string) (string, bool, error) {
+	if err := s.read(); err != nil {
+		return "", false, err
+	}
+
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+func (s *fileSource) keys() ([]string, error) {
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (s *fileSource) read() error {
+	s.once.Do(func() {
+		var f *os.File
+		if f, s.err = os.Open(s.path); s.err != nil {
+			return
+		}
+		defer f.Close()
+
+		if s.values, s.err = decodeValues(f); s.err != nil {
+			s.err = fmt.Errorf("%s: %v", s.path, s.err)
+		}
+	})
+	return s.err
+}
+
+// Set v from the standard chain of flag, environment then default.
+func setValue(v chainValue) error {
+	_, err := resolve(&field{key: v.key(), value: v}, standardSources,
+		defaultLogger, nil)
+	return err
+}
+
+// Resolve f against sources, the first source with a value for the field's
+// name or one of its aliases wins. Using a deprecated name is logged once, a
+// source with different values for several of the names is an error. An
+// encrypted value is decrypted with keys and makes f a secret. Returns
+// the name of the winning source or "" if none had a value, when a field which
+// was set by a source (whose value has since gone) goes back to its default or
+// zero value.
+func resolve(f *field, sources []Source, logger Logger,
+	keys [][]byte) (string, error) {
+	for _, s := range sources {
+		var value, key, name string
+		var winner Source
+		found := false
+
+		for _, k := range f.keys() {
+			src := s
+			if b, ok := src.(binder); ok {
+				src = b.bind(f, k)
+			}
+
+			v, ok, err := src.Lookup(k)
+			if err != nil {
+				return "", f.fieldError(src.Name(), k, "", err)
+			}
+			if !ok {
+				continue
+			}
+
+			if !found {
+				value, key, name, found = v, k, src.Name(), true
+				winner = src
+			} else if v != value {
+				return "", f.fieldError(name, key, "", fmt.Errorf(
+					"%s and %s are both set with different values", key, k))
+			}
+		}
+		if !found {
+			continue
+		}
+
+		if f.isDeprecatedKey(key) && !f.warned {
+			logger.Printf("config: %s is deprecated: %s", key, f.deprecated)
+			f.warned = true
+		}
+
+		// A flag holds its parsed value, which is stored as is, as is the
+		// default parsed when the field was registered
+		if b, ok := winner.(boundFlag); ok && !IsEncrypted(value) &&
+			f.value.assign(b.flag.Get()) {
+			return name, nil
+		}
+		if _, ok := winner.(boundDefault); ok && !IsEncrypted(value) {
+			f.value.reset()
+			return name, nil
+		}
+
+		if IsEncrypted(value) {
+			f.markSecret()
+			plaintext, err := Decrypt(keys, value)
+			if err != nil {
+				return "", f.fieldError(name, key, value, err)
+			}
+			value = plaintext
+		}
+
+		if err := f.value.parse(value); err != nil {
+			return "", f.fieldError(name, key, value, err)
+		}
+		return name, nil
+	}
+
+	if f.source != "" {
+		f.value.reset()
+	}
+	return "", nil
+}
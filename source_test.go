@@ -0,0 +1,136 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mapLookup(m map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+}
+
+func TestEnvSource(t *testing.T) {
+	s := EnvSource(mapLookup(map[string]string{"A": "1"}))
+
+	if v, ok, err := s.Lookup("A"); err != nil || !ok || v != "1" {
+		t.Error("A should be 1")
+	}
+	if _, ok, _ := s.Lookup("B"); ok {
+		t.Error("B should not be found")
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"A": "x", "B": 2.5}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := FileSource(path)
+	if v, ok, err := s.Lookup("B"); err != nil || !ok || v != "2.5" {
+		t.Error("B should be 2.5", v, err)
+	}
+
+	s = FileSource(filepath.Join(dir, "missing.json"))
+	if _, _, err := s.Lookup("A"); err == nil {
+		t.Error("Missing file should fail")
+	}
+}
+
+func TestValueEnv(t *testing.T) {
+	ss := struct {
+		Field float64
+	}{
+		1.0,
+	}
+
+	os.Setenv("CONFIG_TEST_VALUE_ENV", "2.5")
+	defer os.Unsetenv("CONFIG_TEST_VALUE_ENV")
+
+	f := &ss.Field
+
+	flag := Float64Flag{}
+
+	v := Float64Value{"CONFIG_TEST_VALUE_ENV", true, 3.5, &flag, f}
+
+	v.Set()
+
+	if ss.Field != 2.5 {
+		t.Error("env, default, no flag, should be env value 2.5")
+	}
+}
+
+type sourceTest struct {
+	A string `env_def:"def"`
+	B int    `env_def:"1"`
+	C bool
+}
+
+func TestLoaderSources(t *testing.T) {
+	var ss sourceTest
+
+	env := EnvSource(mapLookup(map[string]string{"A": "env", "B": "2"}))
+	file := MapSource("file", map[string]string{"A": "file", "C": "true"})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-B", "3"}),
+		WithSources(file, env, DefaultSource(), FlagSource()))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.A != "file" || ss.B != 2 || !ss.C {
+		t.Error("Sources should be used in order", ss)
+	}
+
+	p := l.Provenance()
+	if p["sourceTest.A"] != "file" || p["sourceTest.B"] != "env" ||
+		p["sourceTest.C"] != "file" {
+		t.Error("Provenance should record the winning source", p)
+	}
+}
+
+func TestLoaderDefaultSources(t *testing.T) {
+	var ss sourceTest
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-C=true"}))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if ss.A != "def" || ss.B != 1 || !ss.C {
+		t.Error("Flags then defaults should be used", ss)
+	}
+
+	p := l.Provenance()
+	if p["sourceTest.A"] != "default" || p["sourceTest.C"] != "flag" {
+		t.Error("Provenance should record the winning source", p)
+	}
+}
+
+func TestLoaderFlagValue(t *testing.T) {
+	ss := struct {
+		At time.Time `env_name:"at" env_layout:"2006-01-02"`
+	}{}
+
+	// The flag's value is stored, not its string in the layout
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-at", "1700000000"}))
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+	if !ss.At.Equal(time.Unix(1700000000, 0)) {
+		t.Error("The flag's time should be kept", ss.At)
+	}
+}
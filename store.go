@@ -0,0 +1,193 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A Store holds the current configuration for concurrent readers. Every update
+// stores a deep copy so the value returned by Get is never modified, Snapshot
+// returns a copy the caller can modify. Subscribers are told of each change in
+// order.
+type Store[T any] struct {
+	current atomic.Pointer[T]
+
+	mu   sync.Mutex // serializes updates and their delivery
+	subs []*subscription[T]
+}
+
+type subscription[T any] struct {
+	fn    func(old, new *T)
+	path  []int // index path of the field, nil for the whole struct
+	field func(old, new interface{})
+}
+
+// Create a Store holding a copy of initial.
+func NewStore[T any](initial *T) *Store[T] {
+	s := &Store[T]{}
+	s.current.Store(deepCopy(initial))
+	return s
+}
+
+// The current configuration, shared with other readers so it must not be
+// modified.
+func (s *Store[T]) Get() *T {
+	return s.current.Load()
+}
+
+// A copy of the current configuration.
+func (s *Store[T]) Snapshot() *T {
+	return deepCopy(s.current.Load())
+}
+
+// Store a copy of v, then call the subscribers with the old and new values.
+// Subscribers are called in the order they subscribed, one update at a time,
+// and must not Update the store.
+func (s *Store[T]) Update(v *T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	new := deepCopy(v)
+	old := s.current.Swap(new)
+
+	for _, sub := range s.subs {
+		if sub.path == nil {
+			sub.fn(old, new)
+			continue
+		}
+
+		o := reflect.ValueOf(old).Elem().FieldByIndex(sub.path)
+		n := reflect.ValueOf(new).Elem().FieldByIndex(sub.path)
+		if !reflect.DeepEqual(o.Interface(), n.Interface()) {
+			sub.field(o.Interface(), n.Interface())
+		}
+	}
+}
+
+// Call fn with the old and new values after every update. The returned func
+// cancels the subscription.
+func (s *Store[T]) Subscribe(fn func(old, new *T)) func() {
+	return s.subscribe(&subscription[T]{fn: fn})
+}
+
+// Call fn with the old and new values of the field at path (Go field names,
+// dotted if nested, e.g. "DB.Host") after every update which changes it.
+func (s *Store[T]) SubscribeField(path string,
+	fn func(old, new interface{})) (func(), error) {
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	var index []int
+	for _, name := range strings.Split(path, ".") {
+		if t.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("No field %s in %s", path,
+				reflect.TypeOf((*T)(nil)).Elem())
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("No field %s in %s", path,
+				reflect.TypeOf((*T)(nil)).Elem())
+		}
+		index = append(index, f.Index...)
+		t = f.Type
+	}
+
+	return s.subscribe(&subscription[T]{path: index, field: fn}), nil
+}
+
+func (s *Store[T]) subscribe(sub *subscription[T]) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subs = append(s.subs, sub)
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for i, x := range s.subs {
+			if x == sub {
+				s.subs = append(s.subs[:i:i], s.subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Update the store with cfg, which l has loaded, now and after every reload.
+// The subscribers are called with l locked: calling any method of l from one
+// (Reload, Provenance...) deadlocks. Have them hand the work to another
+// goroutine if they need l.
+func (s *Store[T]) Track(l *Loader, cfg *T) {
+	l.addHook(func() { s.Update(cfg) })
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s.Update(cfg)
+}
+
+// A deep copy of v, *time.Location, funcs, chans and interfaces are shared.
+// Unexported fields can not be set by reflection so they are copied shallowly,
+// with the struct holding them: pointers, slices and maps in them are shared.
+func deepCopy[T any](v *T) *T {
+	c := new(T)
+	if v != nil {
+		copyValue(reflect.ValueOf(c).Elem(), reflect.ValueOf(v).Elem())
+	}
+	return c
+}
+
+func copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() || src.Type() == locationType {
+			dst.Set(src)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		copyValue(p.Elem(), src.Elem())
+		dst.Set(p)
+
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i))
+			}
+		}
+
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			copyValue(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i))
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			copyValue(v, iter.Value())
+			m.SetMapIndex(iter.Key(), v)
+		}
+		dst.Set(m)
+
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"flag"
+	"sync"
+	"testing"
+	"time"
+)
+
+type storeDB struct {
+	Host string
+	Port int
+}
+
+type storeTest struct {
+	Name    string
+	DB      storeDB
+	Tags    []string
+	Limits  map[string]int
+	Backup  *storeDB
+	Zone    *time.Location
+	Timeout time.Duration
+}
+
+func TestStoreSnapshot(t *testing.T) {
+	cfg := &storeTest{
+		Name:   "a",
+		Tags:   []string{"x"},
+		Limits: map[string]int{"n": 1},
+		Backup: &storeDB{Host: "b"},
+		Zone:   time.UTC,
+	}
+	s := NewStore(cfg)
+
+	cfg.Tags[0] = "y"
+	cfg.Limits["n"] = 2
+	cfg.Backup.Host = "c"
+	if got := s.Get(); got.Tags[0] != "x" || got.Limits["n"] != 1 ||
+		got.Backup.Host != "b" {
+		t.Error("Store should hold a copy of the initial value", got)
+	}
+
+	snap := s.Snapshot()
+	snap.Tags[0] = "z"
+	snap.Backup.Host = "z"
+	if got := s.Get(); got.Tags[0] != "x" || got.Backup.Host != "b" {
+		t.Error("Changing a snapshot should not change the store", got)
+	}
+	if snap.Zone != time.UTC {
+		t.Error("Locations should be shared", snap.Zone)
+	}
+}
+
+func TestStoreSubscribe(t *testing.T) {
+	s := NewStore(&storeTest{Name: "0"})
+
+	var names []string
+	cancel := s.Subscribe(func(old, new *storeTest) {
+		names = append(names, old.Name+">"+new.Name)
+	})
+
+	var hosts []interface{}
+	cancelHost, err := s.SubscribeField("DB.Host",
+		func(old, new interface{}) {
+			hosts = append(hosts, old, new)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Update(&storeTest{Name: "1"})
+	s.Update(&storeTest{Name: "2", DB: storeDB{Host: "h"}})
+	cancel()
+	s.Update(&storeTest{Name: "3", DB: storeDB{Host: "h"}})
+	cancelHost()
+	s.Update(&storeTest{Name: "4"})
+
+	if len(names) != 2 || names[0] != "0>1" || names[1] != "1>2" {
+		t.Error("Unexpected updates", names)
+	}
+	if len(hosts) != 2 || hosts[0] != "" || hosts[1] != "h" {
+		t.Error("DB.Host should only be reported when it changes", hosts)
+	}
+
+	if _, err := s.SubscribeField("DB.User", nil); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+	if _, err := s.SubscribeField("Name.Len", nil); err == nil {
+		t.Error("Expected an error for a field of a non-struct")
+	}
+}
+
+func TestStoreConcurrent(t *testing.T) {
+	s := NewStore(&storeTest{Tags: []string{"0"}})
+
+	var mu sync.Mutex
+	var seen []int
+	s.Subscribe(func(old, new *storeTest) {
+		mu.Lock()
+		seen = append(seen, new.DB.Port)
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cfg := s.Get()
+				if len(cfg.Tags) != 1 || cfg.Tags[0] == "" {
+					t.Error("Inconsistent snapshot", cfg.Tags)
+					return
+				}
+				s.Snapshot().Tags[0] = ""
+			}
+		}()
+	}
+
+	for i := 1; i <= 100; i++ {
+		s.Update(&storeTest{DB: storeDB{Port: i}, Tags: []string{"t"}})
+	}
+	wg.Wait()
+
+	for i, port := range seen {
+		if port != i+1 {
+			t.Fatal("Updates delivered out of order", seen)
+		}
+	}
+}
+
+type trackTest struct {
+	Port int `env_name:"port" env_def:"80"`
+}
+
+func TestStoreTrack(t *testing.T) {
+	values := map[string]string{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil),
+		WithSources(MapSource("test", values), DefaultSource()))
+
+	var cfg trackTest
+	if err := l.Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore(&trackTest{})
+	var ports []interface{}
+	if _, err := s.SubscribeField("Port", func(old, new interface{}) {
+		ports = append(ports, new)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Track(l, &cfg)
+
+	values["port"] = "8080"
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Get().Port != 8080 {
+		t.Error("Store should be updated on reload", s.Get().Port)
+	}
+	if len(ports) != 2 || ports[0] != 80 || ports[1] != 8080 {
+		t.Error("Unexpected port updates", ports)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse s as a time in layout (time.RFC3339 if ""). If s does not match the
+// layout, digits with an optional fraction are seconds since the Unix epoch.
+func ParseTime(s, layout string) (time.Time, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	t, err := time.Parse(layout, s)
+	if err == nil || !isDecimal(s) {
+		return t, err
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return t, err
+	}
+	r.Mul(r, new(big.Rat).SetInt64(int64(time.Second)))
+	ns := new(big.Int).Quo(r.Num(), r.Denom())
+	if !ns.IsInt64() {
+		return time.Time{}, fmt.Errorf("Unix time %s out of range", s)
+	}
+	return time.Unix(0, ns.Int64()).UTC(), nil
+}
+
+// Only digits with at most one decimal point and an optional sign.
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" || strings.Count(s, ".") > 1 {
+		return false
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse a day of the week by name (Monday), abbreviation (mon) or number (0
+// is Sunday).
+func ParseWeekday(s string) (time.Weekday, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n > 6 {
+			return 0, fmt.Errorf("Weekday %d out of range", n)
+		}
+		return time.Weekday(n), nil
+	}
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := d.String()
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown weekday %q", s)
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	if tm, err := ParseTime("2024-03-01T12:30:00Z", ""); err != nil ||
+		!tm.Equal(want) {
+		t.Error("Should parse RFC 3339", tm, err)
+	}
+
+	if tm, err := ParseTime("2024-03-01 12:30", "2006-01-02 15:04"); err != nil ||
+		!tm.Equal(want) {
+		t.Error("Should parse layout", tm, err)
+	}
+
+	if tm, err := ParseTime("1709296200", ""); err != nil || !tm.Equal(want) {
+		t.Error("Should parse Unix seconds", tm, err)
+	}
+
+	if tm, err := ParseTime("1709296200.5", ""); err != nil ||
+		!tm.Equal(want.Add(500*time.Millisecond)) {
+		t.Error("Should parse fractional Unix seconds", tm, err)
+	}
+
+	if tm, err := ParseTime("20240301", "20060102"); err != nil ||
+		!tm.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Layout should take precedence over Unix seconds", tm, err)
+	}
+
+	for _, s := range []string{"", "yesterday", "2024-03-01", "1.2.3"} {
+		if _, err := ParseTime(s, ""); err == nil {
+			t.Error(s, "should not parse")
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	tests := []struct {
+		s string
+		d time.Weekday
+	}{
+		{"Monday", time.Monday},
+		{"sat", time.Saturday},
+		{"SUNDAY", time.Sunday},
+		{"3", time.Wednesday},
+	}
+
+	for _, test := range tests {
+		if d, err := ParseWeekday(test.s); err != nil || d != test.d {
+			t.Error(test.s, "should be", test.d, "not", d, err)
+		}
+	}
+
+	for _, s := range []string{"", "7", "-1", "mo", "Funday"} {
+		if _, err := ParseWeekday(s); err == nil {
+			t.Error(s, "should not parse")
+		}
+	}
+}
+
+type timesTest struct {
+	Cutover     time.Time      `env_def:"2024-03-01T12:30:00Z"`
+	WindowStart time.Time      `env_layout:"2006-01-02 15:04"`
+	Zone        *time.Location `env_def:"Europe/London"`
+	Day         time.Weekday   `env_def:"sun"`
+}
+
+func TestTimesLoad(t *testing.T) {
+	var ss timesTest
+
+	env := map[string]string{"Day": "Tuesday"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-WindowStart", "2024-06-01 02:00"}),
+		WithEnv(mapLookup(env)))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ss.Cutover.Equal(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Error("Cutover should be the default", ss.Cutover)
+	}
+	if !ss.WindowStart.Equal(time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Error("WindowStart should use its layout", ss.WindowStart)
+	}
+	if ss.Zone == nil || ss.Zone.String() != "Europe/London" {
+		t.Error("Zone should be Europe/London", ss.Zone)
+	}
+	if ss.Day != time.Tuesday {
+		t.Error("Day should be from the environment", ss.Day)
+	}
+
+	if d := fs.Lookup("Day").DefValue; d != "Sunday" {
+		t.Error("Help should show the default weekday", d)
+	}
+}
+
+func TestTimesFlagPrecision(t *testing.T) {
+	ss := struct {
+		At time.Time `env_name:"at" env_alias:"when" env_layout:"2006-01-02"`
+	}{}
+
+	// Times on the same day are different values
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, []string{"-at", "1700000000", "-when",
+		"1700000001"}))
+	if err := l.Load(&ss); err == nil {
+		t.Error("Different times should conflict", ss.At)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	l = NewLoader(WithFlagSet(fs, []string{"-at", "1700000000.5"}))
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+	if !ss.At.Equal(time.Unix(1700000000, 5e8)) {
+		t.Error("The time should be kept in full", ss.At)
+	}
+}
+
+func TestTimesNoDefault(t *testing.T) {
+	ss := struct {
+		At time.Time `env_name:"at" env_layout:"2006-01-02"`
+	}{}
+
+	l, help, err := testLoad(nil, nil, nil, &ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.flags.PrintDefaults()
+	if strings.Contains(help.String(), "default") {
+		t.Error("The help should show no default", help.String())
+	}
+}
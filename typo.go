@@ -0,0 +1,166 @@
+/*
+Typo detection, names given in the environment (under the prefix set with
+WithEnvPrefix), files and on the command line which match no field are reported
+with the closest known name.
+*/
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// What to do about unknown names.
+type UnknownMode int
+
+const (
+	UnknownIgnore UnknownMode = iota // Do nothing (the default)
+	UnknownWarn                      // Log them
+	UnknownError                     // Fail the Load
+)
+
+// Report environmental vars starting with prefix which are not the name of a
+// field.
+func WithEnvPrefix(prefix string) Option {
+	return func(l *Loader) {
+		l.envPrefix = prefix
+	}
+}
+
+// Set what to do about unknown environmental vars and file keys.
+func WithUnknown(mode UnknownMode) Option {
+	return func(l *Loader) {
+		l.unknown = mode
+	}
+}
+
+// keyLister is implemented by sources which can list their keys, so unknown
+// keys can be reported.
+type keyLister interface {
+	keys() ([]string, error)
+}
+
+// The names of all the fields and their aliases, and of the variables the
+// profile and keys are read from.
+func (l *Loader) knownKeys() map[string]bool {
+	known := map[string]bool{"profile": true, l.profileEnv: true}
+	if l.keyEnv != "" {
+		known[l.keyEnv] = true
+	}
+	for _, f := range l.fields {
+		for _, k := range f.keys() {
+			known[k] = true
+		}
+	}
+	return known
+}
+
+// Report the unknown environmental vars under the prefix and keys in sources.
+func (l *Loader) checkUnknown() error {
+	if l.unknown == UnknownIgnore {
+		return nil
+	}
+
+	known := l.knownKeys()
+	var msgs []string
+
+	if l.envPrefix != "" && l.environ != nil {
+		var unknown []string
+		for _, kv := range l.environ() {
+			k := strings.SplitN(kv, "=", 2)[0]
+			if strings.HasPrefix(k, l.envPrefix) && !known[k] {
+				unknown = append(unknown, k)
+			}
+		}
+		sort.Strings(unknown)
+		for _, k := range unknown {
+			msgs = append(msgs, "unknown environmental var "+k+suggest(k, known))
+		}
+	}
+
+	for _, s := range l.sources {
+		kl, ok := s.(keyLister)
+		if !ok {
+			continue
+		}
+		keys, err := kl.keys()
+		if err != nil {
+			return err
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !known[k] {
+				msgs = append(msgs, "unknown key "+k+" in "+s.Name()+
+					suggest(k, known))
+			}
+		}
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+	if l.unknown == UnknownError {
+		return errors.New("config: " + strings.Join(msgs, "; "))
+	}
+	for _, msg := range msgs {
+		l.logger.Printf("config: %s", msg)
+	}
+	return nil
+}
+
+// The error for the undefined flag name, suggesting the known flag closest to
+// it if it is close enough to be a typo.
+func (l *Loader) undefinedFlag(name string) error {
+	known := make(map[string]bool)
+	l.flags.VisitAll(func(f *flag.Flag) { known[f.Name] = true })
+	return fmt.Errorf("flag provided but not defined: -%s%s", name,
+		strings.Replace(suggest(name, known), "(did you mean ",
+			"(did you mean -", 1))
+}
+
+// " (did you mean x?)" for the known name x closest to name, if one is close
+// enough to be a typo.
+func suggest(name string, known map[string]bool) string {
+	best, bestDist := "", -1
+	for k := range known {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(k))
+		if bestDist < 0 || d < bestDist || d == bestDist && k < best {
+			best, bestDist = k, d
+		}
+	}
+
+	max := len(name) / 3
+	if max < 2 {
+		max = 2
+	}
+	if best == "" || bestDist > max {
+		return ""
+	}
+	return " (did you mean " + best + "?)"
+}
+
+// The edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding/base64"
+	"flag"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"HOST", "HOTS", 2},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, test := range tests {
+		if d := levenshtein(test.a, test.b); d != test.d {
+			t.Error(test.a, test.b, "should be", test.d, "not", d)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	known := map[string]bool{"MYAPP_DB_HOST": true, "MYAPP_DB_PORT": true}
+
+	if s := suggest("MYAPP_DB_HOTS", known); s != " (did you mean MYAPP_DB_HOST?)" {
+		t.Error("Should suggest MYAPP_DB_HOST", s)
+	}
+	if s := suggest("MYAPP_LOG_LEVEL", known); s != "" {
+		t.Error("Nothing is close to MYAPP_LOG_LEVEL", s)
+	}
+}
+
+type typoTest struct {
+	Host string `env_name:"MYAPP_DB_HOST"`
+	Port int    `env_name:"MYAPP_DB_PORT" env_alias:"MYAPP_PORT"`
+}
+
+func TestUnknownEnv(t *testing.T) {
+	env := map[string]string{
+		"MYAPP_DB_HOTS": "x",
+		"MYAPP_PORT":    "1",
+		"OTHER_VAR":     "y",
+	}
+
+	var ss typoTest
+	logger := &testLogger{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithEnvMap(env), WithLogger(logger),
+		WithEnvPrefix("MYAPP_"), WithUnknown(UnknownWarn))
+
+	if err := l.Load(&ss); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.messages) != 1 || logger.messages[0] != "config: unknown "+
+		"environmental var MYAPP_DB_HOTS (did you mean MYAPP_DB_HOST?)" {
+		t.Error("Should warn about MYAPP_DB_HOTS", logger.messages)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	l = NewLoader(WithFlagSet(fs, nil), WithEnvMap(env),
+		WithEnvPrefix("MYAPP_"), WithUnknown(UnknownError))
+
+	if err := l.Load(&ss); err == nil ||
+		!strings.Contains(err.Error(), "MYAPP_DB_HOTS") {
+		t.Error("Should fail on MYAPP_DB_HOTS", err)
+	}
+}
+
+func TestUnknownProfileAndKeyEnv(t *testing.T) {
+	env := map[string]string{
+		"MYAPP_PROFILE": "dev",
+		"MYAPP_KEY":     base64.StdEncoding.EncodeToString(testKey(t)),
+	}
+
+	var ss typoTest
+	_, _, err := testLoad(nil, env, []Option{WithEnvPrefix("MYAPP_"),
+		WithProfileEnv("MYAPP_PROFILE"), WithKeyEnv("MYAPP_KEY"),
+		WithUnknown(UnknownError)}, &ss)
+	if err != nil {
+		t.Error("The profile and key variables are known", err)
+	}
+}
+
+func TestUnknownFileKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, path, `{"MYAPP_DB_HOST": "a", "MYAPP_DB_PROT": 1}`)
+
+	var ss typoTest
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(WithFlagSet(fs, nil), WithSource(FileSource(path), 0),
+		WithUnknown(UnknownError))
+
+	err := l.Load(&ss)
+	if err == nil || !strings.Contains(err.Error(),
+		"unknown key MYAPP_DB_PROT in file "+path+
+			" (did you mean MYAPP_DB_PORT?)") {
+		t.Error("Should fail on MYAPP_DB_PROT", err)
+	}
+}
+
+func TestUnknownFlag(t *testing.T) {
+	var ss typoTest
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	l := NewLoader(WithFlagSet(fs, []string{"-MYAPP_DB_PORT", "1",
+		"--MYAPP_DB_HOTS=x"}))
+
+	err := l.Load(&ss)
+	if err == nil || err.Error() != "flag provided but not defined: "+
+		"-MYAPP_DB_HOTS (did you mean -MYAPP_DB_HOST?)" {
+		t.Error("Should suggest -MYAPP_DB_HOST", err)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	l = NewLoader(WithFlagSet(fs, []string{"-verbose"}))
+
+	err = l.Load(&ss)
+	if err == nil || err.Error() != "flag provided but not defined: -verbose" {
+		t.Error("Should be the flag package's error", err)
+	}
+}
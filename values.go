@@ -3,14 +3,14 @@ Values are used to store the information for the fields within the structure
 we are trying to initialize - the flag package only allows a name to be used
 once - Values are used to remove this limitation.
 
-If a flag is not set and there is not default value the zero value will be used
-for the field.
+If a flag is not set, the environment variable is not defined and there is not
+a default value the field is left unchanged.
 */
 package config
 
 import (
-	"os"
-	"reflect"
+	"fmt"
+	"math"
 	"strconv"
 	"time"
 )
@@ -21,136 +21,745 @@ type SetValue interface {
 	Set() error
 }
 
+// chainValue is implemented by all the Values so a Loader can resolve them
+// against its chain of Sources.
+type chainValue interface {
+	SetValue
+	// The flag/env name of the field.
+	key() string
+	// The flag registered for the name.
+	configFlag() ConfigFlag
+	// The value of the field.
+	get() interface{}
+	// String representation of the default, if there is one.
+	defaultString() (string, bool)
+	// Parse s and store the result in the field.
+	parse(s string) error
+	// Store x, the value of the field's flag, in the field. False if x is not
+	// of the field's type, when the flag's string is parsed instead.
+	assign(x interface{}) bool
+	// Set the field to its default, the zero value if it has none.
+	reset()
+}
+
 // String Value
 type StringValue struct {
 	name     string
 	isDefVal bool
 	defVal   string
 	flag     ConfigFlag
-	t        reflect.Value
+	t        *string
 }
 
 func (v *StringValue) Set() error {
-	if v.flag.IsSet() {
-		v.t.SetString(v.flag.Get().(string))
-	} else if v.isDefVal {
-		v.t.SetString(v.defVal)
-	}
+	return setValue(v)
+}
+
+func (v *StringValue) key() string {
+	return v.name
+}
+
+func (v *StringValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *StringValue) get() interface{} {
+	return *v.t
+}
+
+func (v *StringValue) defaultString() (string, bool) {
+	return v.defVal, v.isDefVal
+}
+
+func (v *StringValue) parse(s string) error {
+	*v.t = s
 	return nil
 }
 
+func (v *StringValue) assign(x interface{}) bool {
+	s, ok := x.(string)
+	if ok {
+		*v.t = s
+	}
+	return ok
+}
+
+func (v *StringValue) reset() {
+	*v.t = v.defVal
+}
+
 // Int Value
 type IntValue struct {
 	name     string
 	isDefVal bool
 	defVal   int
 	flag     ConfigFlag
-	t        reflect.Value
+	t        *int
 }
 
 func (v *IntValue) Set() error {
-	if v.flag.IsSet() {
-		v.t.SetInt(int64(v.flag.Get().(int)))
-	} else {
-		if s, ok := os.LookupEnv(v.name); ok {
-			i, err := strconv.Atoi(s)
-			if err != nil {
-				return err
-			}
-			v.t.SetInt(int64(i))
-		} else if v.isDefVal {
-			v.t.SetInt(int64(v.defVal))
-		}
+	return setValue(v)
+}
+
+func (v *IntValue) key() string {
+	return v.name
+}
+
+func (v *IntValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *IntValue) get() interface{} {
+	return *v.t
+}
+
+func (v *IntValue) defaultString() (string, bool) {
+	return strconv.Itoa(v.defVal), v.isDefVal
+}
+
+func (v *IntValue) parse(s string) error {
+	i, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if err != nil {
+		return err
 	}
+	*v.t = int(i)
 	return nil
 }
 
+func (v *IntValue) assign(x interface{}) bool {
+	i, ok := x.(int)
+	if ok {
+		*v.t = i
+	}
+	return ok
+}
+
+func (v *IntValue) reset() {
+	*v.t = v.defVal
+}
+
 // Int64 Value
 type Int64Value struct {
 	name     string
 	isDefVal bool
 	defVal   int64
 	flag     ConfigFlag
-	t        reflect.Value
+	t        *int64
 }
 
 func (v *Int64Value) Set() error {
-	if v.flag.IsSet() {
-		v.t.SetInt(v.flag.Get().(int64))
-	} else if v.isDefVal {
-		v.t.SetInt(v.defVal)
+	return setValue(v)
+}
+
+func (v *Int64Value) key() string {
+	return v.name
+}
+
+func (v *Int64Value) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *Int64Value) get() interface{} {
+	return *v.t
+}
+
+func (v *Int64Value) defaultString() (string, bool) {
+	return strconv.FormatInt(v.defVal, 10), v.isDefVal
+}
+
+func (v *Int64Value) parse(s string) error {
+	i, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return err
 	}
+	*v.t = i
 	return nil
 }
 
+func (v *Int64Value) assign(x interface{}) bool {
+	i, ok := x.(int64)
+	if ok {
+		*v.t = i
+	}
+	return ok
+}
+
+func (v *Int64Value) reset() {
+	*v.t = v.defVal
+}
+
 // Uint64 Value
 type Uint64Value struct {
 	name     string
 	isDefVal bool
 	defVal   uint64
 	flag     ConfigFlag
-	t        reflect.Value
+	t        *uint64
 }
 
 func (v *Uint64Value) Set() error {
-	if v.flag.IsSet() {
-		v.t.SetUint(v.flag.Get().(uint64))
-	} else if v.isDefVal {
-		v.t.SetUint(v.defVal)
+	return setValue(v)
+}
+
+func (v *Uint64Value) key() string {
+	return v.name
+}
+
+func (v *Uint64Value) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *Uint64Value) get() interface{} {
+	return *v.t
+}
+
+func (v *Uint64Value) defaultString() (string, bool) {
+	return strconv.FormatUint(v.defVal, 10), v.isDefVal
+}
+
+func (v *Uint64Value) parse(s string) error {
+	u, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return err
 	}
+	*v.t = u
 	return nil
 }
 
+func (v *Uint64Value) assign(x interface{}) bool {
+	u, ok := x.(uint64)
+	if ok {
+		*v.t = u
+	}
+	return ok
+}
+
+func (v *Uint64Value) reset() {
+	*v.t = v.defVal
+}
+
 // Bool Value
 type BoolValue struct {
 	name     string
 	isDefVal bool
 	defVal   bool
 	flag     ConfigFlag
-	t        reflect.Value
+	t        *bool
 }
 
 func (v *BoolValue) Set() error {
-	if v.flag.IsSet() {
-		v.t.SetBool(v.flag.Get().(bool))
-	} else if v.isDefVal {
-		v.t.SetBool(v.defVal)
+	return setValue(v)
+}
+
+func (v *BoolValue) key() string {
+	return v.name
+}
+
+func (v *BoolValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *BoolValue) get() interface{} {
+	return *v.t
+}
+
+func (v *BoolValue) defaultString() (string, bool) {
+	return strconv.FormatBool(v.defVal), v.isDefVal
+}
+
+func (v *BoolValue) parse(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
 	}
+	*v.t = b
 	return nil
 }
 
+func (v *BoolValue) assign(x interface{}) bool {
+	b, ok := x.(bool)
+	if ok {
+		*v.t = b
+	}
+	return ok
+}
+
+func (v *BoolValue) reset() {
+	*v.t = v.defVal
+}
+
 // Float64 Value
 type Float64Value struct {
 	name     string
 	isDefVal bool
 	defVal   float64
 	flag     ConfigFlag
-	t        reflect.Value
+	t        *float64
 }
 
 func (v *Float64Value) Set() error {
-	if v.flag.IsSet() {
-		v.t.SetFloat(v.flag.Get().(float64))
-	} else if v.isDefVal {
-		v.t.SetFloat(v.defVal)
+	return setValue(v)
+}
+
+func (v *Float64Value) key() string {
+	return v.name
+}
+
+func (v *Float64Value) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *Float64Value) get() interface{} {
+	return *v.t
+}
+
+func (v *Float64Value) defaultString() (string, bool) {
+	return strconv.FormatFloat(v.defVal, 'g', -1, 64), v.isDefVal
+}
+
+func (v *Float64Value) parse(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
 	}
+	*v.t = f
 	return nil
 }
 
+func (v *Float64Value) assign(x interface{}) bool {
+	f, ok := x.(float64)
+	if ok {
+		*v.t = f
+	}
+	return ok
+}
+
+func (v *Float64Value) reset() {
+	*v.t = v.defVal
+}
+
 // Duration Value
 type DurationValue struct {
 	name     string
 	isDefVal bool
 	defVal   time.Duration
 	flag     ConfigFlag
-	t        reflect.Value
+	t        *time.Duration
 }
 
 func (v *DurationValue) Set() error {
-	if v.flag.IsSet() {
-		v.t.Set(reflect.ValueOf(v.flag.Get()))
-	} else if v.isDefVal {
-		v.t.Set(reflect.ValueOf(v.defVal))
+	return setValue(v)
+}
+
+func (v *DurationValue) key() string {
+	return v.name
+}
+
+func (v *DurationValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *DurationValue) get() interface{} {
+	return *v.t
+}
+
+func (v *DurationValue) defaultString() (string, bool) {
+	return FormatDuration(v.defVal), v.isDefVal
+}
+
+func (v *DurationValue) parse(s string) error {
+	d, err := ParseDuration(s)
+	if err != nil {
+		return err
 	}
+	*v.t = d
 	return nil
 }
+
+func (v *DurationValue) assign(x interface{}) bool {
+	d, ok := x.(time.Duration)
+	if ok {
+		*v.t = d
+	}
+	return ok
+}
+
+func (v *DurationValue) reset() {
+	*v.t = v.defVal
+}
+
+// ByteSize Value, used for ByteSize fields and int and uint64 fields tagged
+// env_unit:"bytes".
+type ByteSizeValue struct {
+	name     string
+	isDefVal bool
+	defVal   ByteSize
+	flag     ConfigFlag
+	t        interface{} // *int, *int64, *uint64 or *ByteSize
+}
+
+func (v *ByteSizeValue) Set() error {
+	return setValue(v)
+}
+
+func (v *ByteSizeValue) key() string {
+	return v.name
+}
+
+func (v *ByteSizeValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *ByteSizeValue) get() interface{} {
+	return deref(v.t)
+}
+
+func (v *ByteSizeValue) defaultString() (string, bool) {
+	return v.defVal.String(), v.isDefVal
+}
+
+func (v *ByteSizeValue) parse(s string) error {
+	b, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	if !v.store(b) {
+		return fmt.Errorf("Byte size %s overflows %T", s, v.get())
+	}
+	return nil
+}
+
+func (v *ByteSizeValue) assign(x interface{}) bool {
+	// Overflows are reported when parsing
+	b, ok := x.(ByteSize)
+	return ok && v.store(b)
+}
+
+func (v *ByteSizeValue) reset() {
+	v.store(v.defVal)
+}
+
+// Store b in the field, false if it overflows the field's type.
+func (v *ByteSizeValue) store(b ByteSize) bool {
+	switch t := v.t.(type) {
+	case *ByteSize:
+		*t = b
+	case *uint64:
+		*t = uint64(b)
+	case *int64:
+		if b > math.MaxInt64 {
+			return false
+		}
+		*t = int64(b)
+	case *int:
+		if b > math.MaxInt {
+			return false
+		}
+		*t = int(b)
+	}
+	return true
+}
+
+// Time Value
+type TimeValue struct {
+	name     string
+	isDefVal bool
+	defVal   time.Time
+	flag     ConfigFlag
+	t        *time.Time
+	layout   string
+}
+
+func (v *TimeValue) Set() error {
+	return setValue(v)
+}
+
+func (v *TimeValue) key() string {
+	return v.name
+}
+
+func (v *TimeValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *TimeValue) get() interface{} {
+	return *v.t
+}
+
+func (v *TimeValue) defaultString() (string, bool) {
+	if v.layout == "" {
+		return v.defVal.Format(time.RFC3339Nano), v.isDefVal
+	}
+	return v.defVal.Format(v.layout), v.isDefVal
+}
+
+func (v *TimeValue) parse(s string) error {
+	t, err := ParseTime(s, v.layout)
+	if err != nil {
+		return err
+	}
+	*v.t = t
+	return nil
+}
+
+func (v *TimeValue) assign(x interface{}) bool {
+	t, ok := x.(time.Time)
+	if ok {
+		*v.t = t
+	}
+	return ok
+}
+
+func (v *TimeValue) reset() {
+	*v.t = v.defVal
+}
+
+// Location Value
+type LocationValue struct {
+	name     string
+	isDefVal bool
+	defVal   *time.Location
+	flag     ConfigFlag
+	t        **time.Location
+}
+
+func (v *LocationValue) Set() error {
+	return setValue(v)
+}
+
+func (v *LocationValue) key() string {
+	return v.name
+}
+
+func (v *LocationValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *LocationValue) get() interface{} {
+	return *v.t
+}
+
+func (v *LocationValue) defaultString() (string, bool) {
+	if v.defVal == nil {
+		return "", false
+	}
+	return v.defVal.String(), v.isDefVal
+}
+
+func (v *LocationValue) parse(s string) error {
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return err
+	}
+	*v.t = loc
+	return nil
+}
+
+func (v *LocationValue) assign(x interface{}) bool {
+	loc, ok := x.(*time.Location)
+	if ok && loc != nil {
+		*v.t = loc
+	}
+	return ok && loc != nil
+}
+
+func (v *LocationValue) reset() {
+	*v.t = v.defVal
+}
+
+// Weekday Value
+type WeekdayValue struct {
+	name     string
+	isDefVal bool
+	defVal   time.Weekday
+	flag     ConfigFlag
+	t        *time.Weekday
+}
+
+func (v *WeekdayValue) Set() error {
+	return setValue(v)
+}
+
+func (v *WeekdayValue) key() string {
+	return v.name
+}
+
+func (v *WeekdayValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *WeekdayValue) get() interface{} {
+	return *v.t
+}
+
+func (v *WeekdayValue) defaultString() (string, bool) {
+	return v.defVal.String(), v.isDefVal
+}
+
+func (v *WeekdayValue) parse(s string) error {
+	d, err := ParseWeekday(s)
+	if err != nil {
+		return err
+	}
+	*v.t = d
+	return nil
+}
+
+func (v *WeekdayValue) assign(x interface{}) bool {
+	d, ok := x.(time.Weekday)
+	if ok {
+		*v.t = d
+	}
+	return ok
+}
+
+func (v *WeekdayValue) reset() {
+	*v.t = v.defVal
+}
+
+// FeatureFlag Value
+type FeatureFlagValue struct {
+	name     string
+	isDefVal bool
+	defVal   FeatureFlag
+	flag     ConfigFlag
+	t        *FeatureFlag
+}
+
+func (v *FeatureFlagValue) Set() error {
+	return setValue(v)
+}
+
+func (v *FeatureFlagValue) key() string {
+	return v.name
+}
+
+func (v *FeatureFlagValue) configFlag() ConfigFlag {
+	return v.flag
+}
+
+func (v *FeatureFlagValue) get() interface{} {
+	return *v.t
+}
+
+func (v *FeatureFlagValue) defaultString() (string, bool) {
+	return v.defVal.String(), v.isDefVal
+}
+
+func (v *FeatureFlagValue) parse(s string) error {
+	f, err := ParseFeatureFlag(s)
+	if err != nil {
+		return err
+	}
+	f.name = v.name
+	*v.t = f
+	return nil
+}
+
+func (v *FeatureFlagValue) assign(x interface{}) bool {
+	f, ok := x.(FeatureFlag)
+	if ok {
+		f.name = v.name
+		*v.t = f
+	}
+	return ok
+}
+
+func (v *FeatureFlagValue) reset() {
+	f := v.defVal
+	f.name = v.name
+	*v.t = f
+}
+
+// The value of the field p points to, nil if its type is not supported.
+func deref(p interface{}) interface{} {
+	switch p := p.(type) {
+	case *string:
+		return *p
+	case *int:
+		return *p
+	case *int64:
+		return *p
+	case *uint64:
+		return *p
+	case *float64:
+		return *p
+	case *bool:
+		return *p
+	case *ByteSize:
+		return *p
+	case *time.Time:
+		return *p
+	case **time.Location:
+		return *p
+	case *time.Weekday:
+		return *p
+	case *time.Duration:
+		return *p
+	case *FeatureFlag:
+		return *p
+	}
+	return nil
+}
+
+// Whether x, the value of a field, is the zero value of its type.
+func isZero(x interface{}) bool {
+	switch x := x.(type) {
+	case string:
+		return x == ""
+	case int:
+		return x == 0
+	case int64:
+		return x == 0
+	case uint64:
+		return x == 0
+	case float64:
+		return x == 0
+	case bool:
+		return !x
+	case ByteSize:
+		return x == 0
+	case time.Time:
+		return x == time.Time{}
+	case *time.Location:
+		return x == nil
+	case time.Weekday:
+		return x == 0
+	case time.Duration:
+		return x == 0
+	case FeatureFlag:
+		return x.name == "" && x.permyriad == 0 && x.allow == nil &&
+			x.deny == nil
+	}
+	return false
+}
+
+// x, the value of a field with the tags vt, in the form its Value parses.
+func formatValue(x interface{}, vt valueTags) string {
+	switch x := x.(type) {
+	case time.Duration:
+		return FormatDuration(x)
+	case time.Time:
+		if vt.layout == "" {
+			return x.Format(time.RFC3339Nano)
+		}
+		return x.Format(vt.layout)
+	case *time.Location:
+		if x == nil {
+			return ""
+		}
+	case int:
+		if vt.bytes {
+			return ByteSize(x).String()
+		}
+	case int64:
+		if vt.bytes {
+			return ByteSize(x).String()
+		}
+	case uint64:
+		if vt.bytes {
+			return ByteSize(x).String()
+		}
+	}
+	return fmt.Sprint(x)
+}
@@ -1,7 +1,6 @@
 package config
 
 import (
-	"reflect"
 	"testing"
 	"time"
 )
@@ -13,7 +12,7 @@ func TestStringValue1(t *testing.T) {
 		"A",
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := StringFlag{}
 
@@ -33,7 +32,7 @@ func TestStringValue2(t *testing.T) {
 		"A",
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := StringFlag{}
 
@@ -53,7 +52,7 @@ func TestStringValue3(t *testing.T) {
 		"A",
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := StringFlag{}
 
@@ -75,7 +74,7 @@ func TestInt64Value1(t *testing.T) {
 		1,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Int64Flag{}
 
@@ -95,7 +94,7 @@ func TestInt64Value2(t *testing.T) {
 		1,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Int64Flag{}
 
@@ -115,7 +114,7 @@ func TestInt64Value3(t *testing.T) {
 		1,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Int64Flag{}
 
@@ -137,7 +136,7 @@ func TestUint64Value1(t *testing.T) {
 		1,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Uint64Flag{}
 
@@ -157,7 +156,7 @@ func TestUint64Value2(t *testing.T) {
 		1,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Uint64Flag{}
 
@@ -177,7 +176,7 @@ func TestUint64Value3(t *testing.T) {
 		1,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Uint64Flag{}
 
@@ -199,7 +198,7 @@ func TestBoolValue1(t *testing.T) {
 		true,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := BoolFlag{}
 
@@ -219,7 +218,7 @@ func TestBoolValue2(t *testing.T) {
 		false,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := BoolFlag{}
 
@@ -239,7 +238,7 @@ func TestBoolValue3(t *testing.T) {
 		false,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := BoolFlag{}
 
@@ -261,7 +260,7 @@ func TestFloat64Value1(t *testing.T) {
 		1.0,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Float64Flag{}
 
@@ -281,7 +280,7 @@ func TestFloat64Value2(t *testing.T) {
 		1.0,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Float64Flag{}
 
@@ -301,7 +300,7 @@ func TestFloat64Value3(t *testing.T) {
 		1,
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := Float64Flag{}
 
@@ -323,7 +322,7 @@ func TestDurationValue1(t *testing.T) {
 		time.Duration(1),
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := DurationFlag{}
 
@@ -343,7 +342,7 @@ func TestDurationValue2(t *testing.T) {
 		time.Duration(1),
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := DurationFlag{}
 
@@ -363,7 +362,7 @@ func TestDurationValue3(t *testing.T) {
 		time.Duration(1),
 	}
 
-	f := reflect.ValueOf(&ss).Elem().Field(0)
+	f := &ss.Field
 
 	flag := DurationFlag{}
 